@@ -24,14 +24,15 @@
 package aes256go
 
 import (
-	"bytes"
+	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/binary"
 	"errors"
 	"io"
-	"math"
+	"unsafe"
 
+	"github.com/wedkarz02/aes256go/src/bitslice"
 	"github.com/wedkarz02/aes256go/src/consts"
 	"github.com/wedkarz02/aes256go/src/counter"
 	g "github.com/wedkarz02/aes256go/src/galois"
@@ -43,32 +44,92 @@ import (
 // AES256 structure contains key and extended key data.
 type AES256 struct {
 	Key         []byte
-	expandedKey *key.ExpandedKey
+	expandedKey key.ExpandedKey
+	encKey      []uint32
+	decKey      []uint32
+	bsKey       []bitslice.State
+	niEncKey    []byte
+	niDecKey    []byte
+	rounds      int
+	core        Core
+	rand        io.Reader
 }
 
+// Number of counter blocks encrypted at once in counter modes.
+const ctrBatchSize = 8
+
+// AES256 satisfies the cipher.Block interface, so it can be used
+// with the modes of operation from the standard library.
+var _ cipher.Block = (*AES256)(nil)
+
 // NewAES256 initializes new AES cipher
 // with the key hashed to the right size
 // using SHA256
 // and calculates round keys.
-func NewAES256(k []byte) (*AES256, error) {
+func NewAES256(k []byte, opts ...Option) (*AES256, error) {
 	hashedKey := newSHA256(k)
 
 	if len(hashedKey) != consts.KEY_SIZE {
 		return nil, errors.New("invalid key size")
 	}
 
-	a := AES256{Key: hashedKey}
+	a := AES256{Key: hashedKey, rounds: consts.NR}
 
-	var err error
-	a.expandedKey, err = a.newExpKey()
+	if err := a.init(opts); err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+// NewAES256FromKey initializes new AES cipher
+// with a raw key and calculates round keys.
+//
+// The key has to be 16, 24 or 32 bytes long selecting
+// AES-128, AES-192 or AES-256 respectively.
+//
+// Unlike NewAES256, the key is not hashed, so the
+// ciphertexts are interoperable with other AES
+// implementations.
+func NewAES256FromKey(k []byte, opts ...Option) (*AES256, error) {
+	rounds, err := key.Rounds(len(k))
 
 	if err != nil {
 		return nil, err
 	}
 
+	a := AES256{Key: make([]byte, len(k)), rounds: rounds}
+	copy(a.Key, k)
+
+	if err := a.init(opts); err != nil {
+		return nil, err
+	}
+
 	return &a, nil
 }
 
+// Init applies the options and calculates round keys.
+func (a *AES256) init(opts []Option) error {
+	a.core = defaultCore()
+	a.rand = rand.Reader
+
+	for _, opt := range opts {
+		if err := opt(a); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	a.expandedKey, err = a.newExpKey()
+
+	if err != nil {
+		return err
+	}
+
+	a.initCore()
+	return nil
+}
+
 // ClearKey sets all bytes of Key and ExpandedKey to 0x00
 // to make sure that they can't be retrieved from memory.
 func (a *AES256) ClearKey() {
@@ -79,6 +140,20 @@ func (a *AES256) ClearKey() {
 	for i := range a.expandedKey {
 		a.expandedKey[i] = 0x00
 	}
+
+	for i := range a.encKey {
+		a.encKey[i] = 0
+		a.decKey[i] = 0
+	}
+
+	for i := range a.bsKey {
+		a.bsKey[i] = bitslice.State{}
+	}
+
+	for i := range a.niEncKey {
+		a.niEncKey[i] = 0x00
+		a.niDecKey[i] = 0x00
+	}
 }
 
 // NewSHA256 returns a hashed byte slice of the input.
@@ -113,7 +188,7 @@ func newInvSBox(sb *sbox.SBOX) *sbox.SBOX {
 // https://en.wikipedia.org/wiki/AES_key_schedule
 //
 // https://www.samiam.org/key-schedule.html
-func (a *AES256) newExpKey() (*key.ExpandedKey, error) {
+func (a *AES256) newExpKey() (key.ExpandedKey, error) {
 	xKey, err := key.ExpandKey(a.Key)
 
 	if err != nil {
@@ -264,7 +339,7 @@ func (a *AES256) addRoundKey(state []byte, roundIdx int) ([]byte, error) {
 		return nil, errors.New("state size not matching the block size")
 	}
 
-	if roundIdx > consts.NR {
+	if roundIdx > a.rounds {
 		return nil, errors.New("round index out of range")
 	}
 
@@ -280,7 +355,7 @@ func (a *AES256) addRoundKey(state []byte, roundIdx int) ([]byte, error) {
 	return newState, nil
 }
 
-// EncryptBlock performs 256 bit AES encryption
+// EncryptBlock performs AES encryption
 // of one 16 byte block.
 //
 // https://en.wikipedia.org/wiki/Advanced_Encryption_Standard
@@ -289,6 +364,38 @@ func (a *AES256) EncryptBlock(state []byte) ([]byte, error) {
 		return nil, errors.New("state size not matching the block size")
 	}
 
+	cipherText := make([]byte, consts.BLOCK_SIZE)
+	a.encryptBlocks(cipherText, state)
+
+	return cipherText, nil
+}
+
+// DecryptBlock performs AES decryption
+// of one 16 byte block.
+//
+// https://en.wikipedia.org/wiki/Advanced_Encryption_Standard
+func (a *AES256) DecryptBlock(state []byte) ([]byte, error) {
+	if len(state) != consts.BLOCK_SIZE {
+		return nil, errors.New("state size not matching the block size")
+	}
+
+	plainText := make([]byte, consts.BLOCK_SIZE)
+	a.decryptBlocks(plainText, state)
+
+	return plainText, nil
+}
+
+// EncryptBlockRef performs AES encryption of one 16 byte block
+// step by step, exactly as described in the specification.
+// It is much slower than EncryptBlock and only serves as a
+// reference for the table driven core.
+//
+// https://en.wikipedia.org/wiki/Advanced_Encryption_Standard
+func (a *AES256) encryptBlockRef(state []byte) ([]byte, error) {
+	if len(state) != consts.BLOCK_SIZE {
+		return nil, errors.New("state size not matching the block size")
+	}
+
 	var err error
 	cipherText := make([]byte, len(state))
 	copy(cipherText, state)
@@ -298,7 +405,7 @@ func (a *AES256) EncryptBlock(state []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	for roundIdx := 1; roundIdx < consts.NR; roundIdx++ {
+	for roundIdx := 1; roundIdx < a.rounds; roundIdx++ {
 		cipherText, err = a.subBytes(cipherText)
 		if err != nil {
 			return nil, err
@@ -330,7 +437,7 @@ func (a *AES256) EncryptBlock(state []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	cipherText, err = a.addRoundKey(cipherText, consts.NR)
+	cipherText, err = a.addRoundKey(cipherText, a.rounds)
 	if err != nil {
 		return nil, err
 	}
@@ -338,11 +445,13 @@ func (a *AES256) EncryptBlock(state []byte) ([]byte, error) {
 	return cipherText, nil
 }
 
-// DecryptBlock performs 256 bit AES decryption
-// of one 16 byte block.
+// DecryptBlockRef performs AES decryption of one 16 byte block
+// step by step, exactly as described in the specification.
+// It is much slower than DecryptBlock and only serves as a
+// reference for the table driven core.
 //
 // https://en.wikipedia.org/wiki/Advanced_Encryption_Standard
-func (a *AES256) DecryptBlock(state []byte) ([]byte, error) {
+func (a *AES256) decryptBlockRef(state []byte) ([]byte, error) {
 	if len(state) != consts.BLOCK_SIZE {
 		return nil, errors.New("state size not matching the block size")
 	}
@@ -351,12 +460,12 @@ func (a *AES256) DecryptBlock(state []byte) ([]byte, error) {
 	plainText := make([]byte, len(state))
 	copy(plainText, state)
 
-	plainText, err = a.addRoundKey(plainText, consts.NR)
+	plainText, err = a.addRoundKey(plainText, a.rounds)
 	if err != nil {
 		return nil, err
 	}
 
-	for roundIdx := consts.NR - 1; roundIdx > 0; roundIdx-- {
+	for roundIdx := a.rounds - 1; roundIdx > 0; roundIdx-- {
 		plainText, err = a.invShiftRows(plainText)
 		if err != nil {
 			return nil, err
@@ -396,150 +505,188 @@ func (a *AES256) DecryptBlock(state []byte) ([]byte, error) {
 	return plainText, nil
 }
 
-// Data encryption using ECB mode.
-//
-// https://en.wikipedia.org/wiki/Block_cipher_mode_of_operation#Electronic_codebook_(ECB)
-func (a *AES256) EncryptECB(plainText []byte, pad padding.Pad) ([]byte, error) {
-	paddedPlain := pad(plainText)
-	var cipherText []byte
+// BlockSize returns the AES block size in bytes.
+func (a *AES256) BlockSize() int {
+	return consts.BLOCK_SIZE
+}
 
-	for i := 0; i < len(paddedPlain); i += consts.BLOCK_SIZE {
-		encBlock, err := a.EncryptBlock(paddedPlain[i : i+consts.BLOCK_SIZE])
+// Encrypt encrypts the first block in src into dst.
+// Dst and src must overlap entirely or not at all.
+//
+// Unlike EncryptBlock, Encrypt does not allocate and panics
+// if either of the buffers is shorter than a block, just like
+// the cipher.Block returned by crypto/aes.
+func (a *AES256) Encrypt(dst, src []byte) {
+	if len(src) < consts.BLOCK_SIZE {
+		panic("aes256go: input not full block")
+	}
 
-		if err != nil {
-			return nil, err
-		}
+	if len(dst) < consts.BLOCK_SIZE {
+		panic("aes256go: output not full block")
+	}
 
-		cipherText = append(cipherText, encBlock...)
+	if inexactOverlap(dst[:consts.BLOCK_SIZE], src[:consts.BLOCK_SIZE]) {
+		panic("aes256go: invalid buffer overlap")
 	}
 
-	return cipherText, nil
+	a.encryptBlocks(dst[:consts.BLOCK_SIZE], src[:consts.BLOCK_SIZE])
 }
 
-// Data decryption using ECB mode.
+// Decrypt decrypts the first block in src into dst.
+// Dst and src must overlap entirely or not at all.
 //
-// https://en.wikipedia.org/wiki/Block_cipher_mode_of_operation#Electronic_codebook_(ECB)
-func (a *AES256) DecryptECB(cipherText []byte, unpad padding.UnPad) ([]byte, error) {
-	var paddedPlain []byte
+// Unlike DecryptBlock, Decrypt does not allocate and panics
+// if either of the buffers is shorter than a block, just like
+// the cipher.Block returned by crypto/aes.
+func (a *AES256) Decrypt(dst, src []byte) {
+	if len(src) < consts.BLOCK_SIZE {
+		panic("aes256go: input not full block")
+	}
 
-	for i := 0; i < len(cipherText); i += consts.BLOCK_SIZE {
-		decBlock, err := a.DecryptBlock(cipherText[i : i+consts.BLOCK_SIZE])
+	if len(dst) < consts.BLOCK_SIZE {
+		panic("aes256go: output not full block")
+	}
 
-		if err != nil {
-			return nil, err
-		}
+	if inexactOverlap(dst[:consts.BLOCK_SIZE], src[:consts.BLOCK_SIZE]) {
+		panic("aes256go: invalid buffer overlap")
+	}
+
+	a.decryptBlocks(dst[:consts.BLOCK_SIZE], src[:consts.BLOCK_SIZE])
+}
 
-		paddedPlain = append(paddedPlain, decBlock...)
+// InexactOverlap reports whether x and y share memory
+// at any non-corresponding index.
+func inexactOverlap(x, y []byte) bool {
+	if len(x) == 0 || len(y) == 0 || &x[0] == &y[0] {
+		return false
 	}
 
-	plainText := unpad(paddedPlain)
-	return plainText, nil
+	return uintptr(unsafe.Pointer(&x[0])) <= uintptr(unsafe.Pointer(&y[len(y)-1])) &&
+		uintptr(unsafe.Pointer(&y[0])) <= uintptr(unsafe.Pointer(&x[len(x)-1]))
+}
+
+// Data encryption using ECB mode.
+//
+// https://en.wikipedia.org/wiki/Block_cipher_mode_of_operation#Electronic_codebook_(ECB)
+func (a *AES256) EncryptECB(plainText []byte, pad padding.Pad) ([]byte, error) {
+	return a.encryptChain(ecbMode{}, nil, plainText, pad)
+}
+
+// Data decryption using ECB mode.
+//
+// https://en.wikipedia.org/wiki/Block_cipher_mode_of_operation#Electronic_codebook_(ECB)
+func (a *AES256) DecryptECB(cipherText []byte, unpad padding.UnPad) ([]byte, error) {
+	return a.decryptChain(ecbMode{}, cipherText, unpad)
 }
 
 // Data encryption using CBC mode.
 //
 // https://en.wikipedia.org/wiki/Block_cipher_mode_of_operation#Cipher_block_chaining_(CBC)
 func (a *AES256) EncryptCBC(plainText []byte, pad padding.Pad) ([]byte, error) {
-	paddedPlain := pad(plainText)
-	var cipherText []byte
-
 	iv := make([]byte, consts.IV_SIZE)
-	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
-		return nil, errors.New("iv initialization failed")
+	if err := readRandom(a.rand, iv, "iv"); err != nil {
+		return nil, err
 	}
 
-	cipherText = append(cipherText, iv...)
-
-	for i := 0; i < len(paddedPlain); i += consts.BLOCK_SIZE {
-		maskedBlock := g.GxorBlocks(paddedPlain[i:i+consts.BLOCK_SIZE], iv)
-		encBlock, err := a.EncryptBlock(maskedBlock)
-
-		if err != nil {
-			return nil, err
-		}
+	return a.EncryptCBCWithIV(iv, plainText, pad)
+}
 
-		cipherText = append(cipherText, encBlock...)
-		copy(iv, encBlock)
+// Data encryption using CBC mode with the given IV,
+// which is prepended to the cipherText.
+//
+// In CBC being unique is not enough, the IV has to be unpredictable
+// to anyone choosing plainTexts, like a random block or an encrypted
+// message counter. Otherwise the first plainText block can be guessed
+// and checked against an earlier cipherText.
+//
+// https://en.wikipedia.org/wiki/Block_cipher_mode_of_operation#Cipher_block_chaining_(CBC)
+func (a *AES256) EncryptCBCWithIV(iv []byte, plainText []byte, pad padding.Pad) ([]byte, error) {
+	if len(iv) != consts.IV_SIZE {
+		return nil, errors.New("invalid iv size")
 	}
 
-	return cipherText, nil
+	return a.encryptChain(cbcMode{iv: iv}, iv, plainText, pad)
 }
 
 // Data decryption using CBC mode.
 //
 // https://en.wikipedia.org/wiki/Block_cipher_mode_of_operation#Cipher_block_chaining_(CBC)
 func (a *AES256) DecryptCBC(cipherText []byte, unpad padding.UnPad) ([]byte, error) {
+	if len(cipherText) < consts.IV_SIZE {
+		return nil, errors.New("cipherText size not matching the block size")
+	}
+
 	iv := cipherText[:consts.IV_SIZE]
-	var strippedCipher []byte
-	var paddedPlain []byte
+	return a.decryptChain(cbcMode{iv: iv}, cipherText[consts.IV_SIZE:], unpad)
+}
 
-	strippedCipher = append(strippedCipher, cipherText[consts.IV_SIZE:]...)
+// CbcEncrypt chains whole blocks of src into dst in CBC mode.
+func (a *AES256) cbcEncrypt(dst, src, iv []byte) {
+	prev := iv
 
-	for i := 0; i < len(strippedCipher); i += consts.BLOCK_SIZE {
-		decBlock, err := a.DecryptBlock(strippedCipher[i : i+consts.BLOCK_SIZE])
+	for i := 0; i < len(src); i += consts.BLOCK_SIZE {
+		block := dst[i : i+consts.BLOCK_SIZE]
 
-		if err != nil {
-			return nil, err
+		for j := range block {
+			block[j] = src[i+j] ^ prev[j]
 		}
 
-		decBlock = g.GxorBlocks(decBlock, iv)
-		copy(iv, strippedCipher[i:i+consts.BLOCK_SIZE])
+		a.encryptBlocks(block, block)
+		prev = block
+	}
+}
 
-		paddedPlain = append(paddedPlain, decBlock...)
+// CbcDecrypt decrypts whole blocks of src into dst in CBC mode.
+// Dst and src can't overlap.
+func (a *AES256) cbcDecrypt(dst, src, iv []byte) {
+	if len(src) == 0 {
+		return
 	}
 
-	plainText := unpad(paddedPlain)
-	return plainText, nil
+	// Unlike encryption, CBC decryption of every block only
+	// depends on the ciphertext, so all of the blocks can be
+	// decrypted at once and chained afterwards.
+	a.decryptBlocks(dst, src)
+
+	xorBlock(dst, iv)
+
+	for i := consts.BLOCK_SIZE; i < len(src); i++ {
+		dst[i] ^= src[i-consts.BLOCK_SIZE]
+	}
 }
 
 // Data encryption using CFB mode.
 //
 // 1 <= s <= 16 (block size)
 //
+// The segment size s is given in bytes. For bit segments,
+// like CFB-1, use EncryptCFBBits.
+//
 // https://en.wikipedia.org/wiki/Block_cipher_mode_of_operation#Cipher_feedback_(CFB)
 func (a *AES256) EncryptCFB(plainText []byte, s int) ([]byte, error) {
-	if s < 1 || s > consts.BLOCK_SIZE {
-		return nil, errors.New("invalid segment size")
-	}
-
 	iv := make([]byte, consts.IV_SIZE)
-	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
-		return nil, errors.New("iv initialization failed")
-	}
-
-	initialIV := make([]byte, len(iv))
-	copy(initialIV, iv)
-
-	var cipherText []byte
-	var i int
-
-	for i = 0; i < len(plainText)-s; i += s {
-		encIV, err := a.EncryptBlock(iv)
-
-		if err != nil {
-			return nil, err
-		}
-
-		streamBlock := encIV[:s]
-		cipherBlock := g.GxorBlocks(plainText[i:i+s], streamBlock)
-		cipherText = append(cipherText, cipherBlock...)
-
-		shiftReg := append(iv[s:], cipherBlock...)
-		copy(iv, shiftReg)
+	if err := readRandom(a.rand, iv, "iv"); err != nil {
+		return nil, err
 	}
 
-	lastEncIV, err := a.EncryptBlock(iv)
+	return a.EncryptCFBWithIV(iv, plainText, s)
+}
 
-	if err != nil {
-		return nil, err
+// Data encryption using CFB mode with the given IV,
+// which is prepended to the cipherText.
+//
+// 1 <= s <= 16 (block size)
+//
+// The IV has to be unpredictable like in CBC. A repeated IV also
+// reveals the XOR of the first segments of both plainTexts.
+//
+// https://en.wikipedia.org/wiki/Block_cipher_mode_of_operation#Cipher_feedback_(CFB)
+func (a *AES256) EncryptCFBWithIV(iv []byte, plainText []byte, s int) ([]byte, error) {
+	if s < 1 || s > consts.BLOCK_SIZE {
+		return nil, errors.New("invalid segment size")
 	}
 
-	lastStreamBlock := lastEncIV[:s]
-	lastCipherBlock := g.GxorBlocks(plainText[i:], lastStreamBlock)
-	cipherText = append(cipherText, lastCipherBlock...)
-
-	cipherText = append(initialIV, cipherText...)
-	return cipherText, nil
+	return a.EncryptCFBBitsWithIV(iv, plainText, 8*s)
 }
 
 // Data decryption using CFB mode.
@@ -552,52 +699,35 @@ func (a *AES256) DecryptCFB(cipherText []byte, s int) ([]byte, error) {
 		return nil, errors.New("invalid segment size")
 	}
 
-	iv := make([]byte, consts.IV_SIZE)
-	copy(iv, cipherText[:consts.IV_SIZE])
-
-	cipherText = cipherText[consts.IV_SIZE:]
-	var plainText []byte
-	var i int
-
-	for i = 0; i < len(cipherText)-s; i += s {
-		encIV, err := a.EncryptBlock(iv)
-
-		if err != nil {
-			return nil, err
-		}
-
-		streamBlock := encIV[:s]
-		plainBlock := g.GxorBlocks(cipherText[i:i+s], streamBlock)
-		plainText = append(plainText, plainBlock...)
-
-		shiftReg := append(iv[s:], cipherText[i:i+s]...)
-		copy(iv, shiftReg)
-	}
-
-	lastEncIV, err := a.EncryptBlock(iv)
+	return a.DecryptCFBBits(cipherText, 8*s)
+}
 
-	if err != nil {
+// Data encryption using OFB mode.
+//
+// https://en.wikipedia.org/wiki/Block_cipher_mode_of_operation#Output_feedback_(OFB)
+func (a *AES256) EncryptOFB(plainText []byte) ([]byte, error) {
+	iv := make([]byte, consts.IV_SIZE)
+	if err := readRandom(a.rand, iv, "iv"); err != nil {
 		return nil, err
 	}
 
-	lastStreamBlock := lastEncIV[:s]
-	lastPlainBlock := g.GxorBlocks(cipherText[i:], lastStreamBlock)
-	plainText = append(plainText, lastPlainBlock...)
-
-	return plainText, nil
+	return a.EncryptOFBWithIV(iv, plainText)
 }
 
-// Data encryption using OFB mode.
+// Data encryption using OFB mode with the given IV,
+// which is prepended to the cipherText.
+//
+// The IV must never be reused with the same key.
 //
 // https://en.wikipedia.org/wiki/Block_cipher_mode_of_operation#Output_feedback_(OFB)
-func (a *AES256) EncryptOFB(plainText []byte) ([]byte, error) {
-	iv := make([]byte, consts.IV_SIZE)
-	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
-		return nil, errors.New("iv initialization failed")
+func (a *AES256) EncryptOFBWithIV(iv []byte, plainText []byte) ([]byte, error) {
+	if len(iv) != consts.IV_SIZE {
+		return nil, errors.New("invalid iv size")
 	}
 
 	initialIV := make([]byte, len(iv))
 	copy(initialIV, iv)
+	iv = append([]byte(nil), iv...)
 
 	var cipherText []byte
 	var i int
@@ -670,24 +800,44 @@ func (a *AES256) DecryptOFB(cipherText []byte) ([]byte, error) {
 // Data encryption using CTR mode.
 //
 // Please keep in mind that the counter is a 32 bit number, therefore you can
-// encrypt 2^32 blocks of data (roughly 68 gigabytes) before it resets. If you need to encrypt more
-// data than that, split it to multiple chunks and run the function for each one.
+// encrypt 2^32 blocks of data (roughly 68 gigabytes) with one nonce. An error is returned
+// for larger inputs, so either split the data into multiple chunks and run the function
+// for each one, or use EncryptCTRWithCounter with a wider counter.
 //
 // https://en.wikipedia.org/wiki/Block_cipher_mode_of_operation#Counter_(CTR)
 func (a *AES256) EncryptCTR(plainText []byte) ([]byte, error) {
 	nonce := make([]byte, consts.NONCE_SIZE)
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+	if err := readRandom(a.rand, nonce, "nonce"); err != nil {
 		return nil, err
 	}
 
-	ctr := counter.NewCounter()
-	cipherText, err := a.coreBlockCTR(plainText, nonce, ctr)
+	return a.EncryptCTRWithNonce(nonce, plainText)
+}
+
+// Data encryption using CTR mode with the given 12 byte nonce,
+// which is prepended to the cipherText. The counter starts at 0.
+//
+// The nonce must never be reused with the same key.
+//
+// https://en.wikipedia.org/wiki/Block_cipher_mode_of_operation#Counter_(CTR)
+func (a *AES256) EncryptCTRWithNonce(nonce []byte, plainText []byte) ([]byte, error) {
+	if len(nonce) != consts.NONCE_SIZE {
+		return nil, errors.New("invalid nonce size")
+	}
+
+	ctr, err := counter.NewNonceCounter(nonce)
 
 	if err != nil {
 		return nil, err
 	}
 
-	cipherText = append(nonce, cipherText...)
+	cipherText, err := a.coreBlockCTR(plainText, ctr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cipherText = append(append([]byte(nil), nonce...), cipherText...)
 	return cipherText, nil
 }
 
@@ -695,13 +845,17 @@ func (a *AES256) EncryptCTR(plainText []byte) ([]byte, error) {
 //
 // https://en.wikipedia.org/wiki/Block_cipher_mode_of_operation#Counter_(CTR)
 func (a *AES256) DecryptCTR(cipherText []byte) ([]byte, error) {
-	nonce := make([]byte, consts.NONCE_SIZE)
-	copy(nonce, cipherText[:consts.NONCE_SIZE])
+	if len(cipherText) < consts.NONCE_SIZE {
+		return nil, errors.New("cipherText too short: missing nonce")
+	}
+
+	ctr, err := counter.NewNonceCounter(cipherText[:consts.NONCE_SIZE])
 
-	cipherText = cipherText[consts.NONCE_SIZE:]
+	if err != nil {
+		return nil, err
+	}
 
-	ctr := counter.NewCounter()
-	plainText, err := a.coreBlockCTR(cipherText, nonce, ctr)
+	plainText, err := a.coreBlockCTR(cipherText[consts.NONCE_SIZE:], ctr)
 
 	if err != nil {
 		return nil, err
@@ -710,52 +864,75 @@ func (a *AES256) DecryptCTR(cipherText []byte) ([]byte, error) {
 	return plainText, nil
 }
 
-// CoreBlockCTR is used to encrypt/decrypt the data in counter modes (CTR and GCM).
-func (a *AES256) coreBlockCTR(data []byte, nonce []byte, ctr *counter.Counter) ([]byte, error) {
-	if len(nonce) != consts.NONCE_SIZE {
-		return nil, errors.New("invalid nonce size")
-	}
+// Data encryption using CTR mode with the given 16 byte initial
+// counter block, which is prepended to the cipherText.
+//
+// Only the last width bytes of the block are incremented, the leading
+// ones are a fixed nonce. This allows any nonce || counter split,
+// like 64/64 or a full 128 bit counter (width 16).
+//
+// The counter block does not have to be secret or unpredictable, but
+// no counter block may ever be used twice with the same key, so the
+// ranges covered by different messages must not overlap.
+//
+// https://nvlpubs.nist.gov/nistpubs/Legacy/SP/nistspecialpublication800-38a.pdf (Appendix B)
+func (a *AES256) EncryptCTRWithCounter(counterBlock []byte, width int, plainText []byte) ([]byte, error) {
+	ctr, err := counter.NewCounterBlock(counterBlock, width)
 
-	if data == nil {
-		return data, nil
+	if err != nil {
+		return nil, err
 	}
 
-	var inputBlock []byte
-	inputBlock = append(inputBlock, nonce...)
-	inputBlock = append(inputBlock, ctr.Bytes[:]...)
-
-	var outputData []byte
-	var i int
-
-	lastLen := len(data) % consts.BLOCK_SIZE
-
-	for i = 0; i < len(data)-lastLen; i += consts.BLOCK_SIZE {
-		encBlock, err := a.EncryptBlock(inputBlock)
+	cipherText, err := a.coreBlockCTR(plainText, ctr)
 
-		if err != nil {
-			return nil, err
-		}
+	if err != nil {
+		return nil, err
+	}
 
-		cipherBlock := g.GxorBlocks(data[i:i+consts.BLOCK_SIZE], encBlock)
-		outputData = append(outputData, cipherBlock...)
+	cipherText = append(append([]byte(nil), counterBlock...), cipherText...)
+	return cipherText, nil
+}
 
-		ctr.Increment()
+// Data decryption using CTR mode with the initial counter block
+// prepended to the cipherText and a counter of width bytes.
+//
+// https://nvlpubs.nist.gov/nistpubs/Legacy/SP/nistspecialpublication800-38a.pdf (Appendix B)
+func (a *AES256) DecryptCTRWithCounter(cipherText []byte, width int) ([]byte, error) {
+	if len(cipherText) < consts.BLOCK_SIZE {
+		return nil, errors.New("cipherText too short: missing counter block")
+	}
 
-		var incrementedBlock []byte
-		incrementedBlock = append(incrementedBlock, nonce...)
-		incrementedBlock = append(incrementedBlock, ctr.Bytes[:]...)
+	ctr, err := counter.NewCounterBlock(cipherText[:consts.BLOCK_SIZE], width)
 
-		copy(inputBlock, incrementedBlock)
+	if err != nil {
+		return nil, err
 	}
 
-	lastEncBlock, err := a.EncryptBlock(inputBlock)
+	plainText, err := a.coreBlockCTR(cipherText[consts.BLOCK_SIZE:], ctr)
 
 	if err != nil {
 		return nil, err
 	}
 
-	lastCipherBlock := g.GxorBlocks(data[i:], lastEncBlock[:lastLen])
-	outputData = append(outputData, lastCipherBlock...)
+	return plainText, nil
+}
+
+// CoreBlockCTR is used to encrypt/decrypt the data in counter modes (CTR and GCM).
+//
+// An error is returned before anything is encrypted if the counter
+// would wrap around, since that would repeat the keystream.
+func (a *AES256) coreBlockCTR(data []byte, ctr *counter.Counter) ([]byte, error) {
+	if data == nil {
+		return data, nil
+	}
+
+	blocks := (uint64(len(data)) + consts.BLOCK_SIZE - 1) / consts.BLOCK_SIZE
+	if blocks > ctr.Remaining() {
+		return nil, counter.ErrOverflow
+	}
+
+	outputData := make([]byte, len(data))
+	newCTRStream(a, ctr).XORKeyStream(outputData, data)
 
 	return outputData, nil
 }
@@ -767,30 +944,43 @@ func (a *AES256) coreBlockCTR(data []byte, nonce []byte, ctr *counter.Counter) (
 //
 // https://nvlpubs.nist.gov/nistpubs/Legacy/SP/nistspecialpublication800-38d.pdf
 func (a *AES256) EncryptGCM(plainText []byte, authData []byte) ([]byte, error) {
-	nonce := make([]byte, consts.NONCE_SIZE)
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+	aead, err := a.NewGCM()
+
+	if err != nil {
 		return nil, err
 	}
 
-	ctr := counter.NewCounter()
-	ctr.Increment()
-
-	cipherText, err := a.coreBlockCTR(plainText, nonce, ctr)
-
-	if err != nil {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plainText)+aead.Overhead())
+	if err := readRandom(a.rand, nonce, "nonce"); err != nil {
 		return nil, err
 	}
 
-	tag, err := a.GMAC(cipherText, authData, nonce)
+	return aead.Seal(nonce, nonce, plainText, authData), nil
+}
+
+// Data encryption and authentication using GCM mode with the given
+// 12 byte nonce. Nonce is prepended to the cipherText and the
+// authentication tag is appended to the cipherText.
+//
+// The nonce must never be reused with the same key, otherwise both
+// confidentiality and authenticity are lost.
+//
+// https://nvlpubs.nist.gov/nistpubs/Legacy/SP/nistspecialpublication800-38d.pdf
+func (a *AES256) EncryptGCMWithNonce(nonce []byte, plainText []byte, authData []byte) ([]byte, error) {
+	aead, err := a.NewGCM()
 
 	if err != nil {
 		return nil, err
 	}
 
-	cipherText = append(nonce, cipherText...)
-	cipherText = append(cipherText, tag...)
+	if len(nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid nonce size")
+	}
 
-	return cipherText, nil
+	output := make([]byte, 0, len(nonce)+len(plainText)+aead.Overhead())
+	output = append(output, nonce...)
+
+	return aead.Seal(output, nonce, plainText, authData), nil
 }
 
 // Data decryption and authentication using GCM mode. Nonce is prepended to the cipherText
@@ -800,34 +990,34 @@ func (a *AES256) EncryptGCM(plainText []byte, authData []byte) ([]byte, error) {
 //
 // https://nvlpubs.nist.gov/nistpubs/Legacy/SP/nistspecialpublication800-38d.pdf
 func (a *AES256) DecryptGCM(cipherText []byte, authData []byte) ([]byte, error) {
-	nonce := make([]byte, consts.NONCE_SIZE)
-	copy(nonce, cipherText[:consts.NONCE_SIZE])
-
-	tag := make([]byte, consts.TAG_SIZE)
-	copy(tag, cipherText[len(cipherText)-consts.TAG_SIZE:])
-
-	cipherText = cipherText[consts.NONCE_SIZE : len(cipherText)-consts.TAG_SIZE]
-
-	testTag, err := a.GMAC(cipherText, authData, nonce)
+	aead, err := a.NewGCM()
 
 	if err != nil {
 		return nil, err
 	}
 
-	if !bytes.Equal(tag, testTag) {
-		return nil, errors.New("GCM authentication failed: Invalid authentication tag")
+	if len(cipherText) < aead.NonceSize()+aead.Overhead() {
+		return nil, errors.New("GCM authentication failed: cipherText too short")
 	}
 
-	ctr := counter.NewCounter()
-	ctr.Increment()
+	nonce := cipherText[:aead.NonceSize()]
+	return aead.Open(nil, nonce, cipherText[aead.NonceSize():], authData)
+}
 
-	plainText, err := a.coreBlockCTR(cipherText, nonce, ctr)
+// NewGCMCounter returns the counter used for GCM encryption.
+// The pre-counter block J0 (nonce || 1) is reserved for the
+// tag, so the data is encrypted starting from inc32(J0).
+func newGCMCounter(nonce []byte) (*counter.Counter, error) {
+	ctr, err := counter.NewNonceCounter(nonce)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return plainText, nil
+	ctr.Increment()
+	ctr.Increment()
+
+	return ctr, nil
 }
 
 // GMAC calculates a tag used to authenticate data during GCM encryption/decryption.
@@ -845,24 +1035,27 @@ func (a *AES256) GMAC(cipherData []byte, authData []byte, nonce []byte) ([]byte,
 		return nil, err
 	}
 
-	preCtr := counter.NewCounter()
-	preCtr.Increment()
+	preCtr, err := counter.NewNonceCounter(nonce)
+
+	if err != nil {
+		return nil, err
+	}
 
-	cipherPadding := make([]byte, 16*int(math.Ceil(float64(8*len(cipherData))/128.0))-len(cipherData))
-	authPadding := make([]byte, 16*int(math.Ceil(float64(8*len(authData))/128.0))-len(authData))
+	preCtr.Increment()
 
-	lenC := make([]byte, 8)
-	lenA := make([]byte, 8)
-	binary.BigEndian.PutUint64(lenC, uint64(len(cipherData)))
-	binary.BigEndian.PutUint64(lenA, uint64(len(authData)))
+	// GHASH input: A || 0^v || C || 0^u || [len(A)]64 || [len(C)]64,
+	// with both lengths given in bits.
+	authLen := (len(authData) + consts.BLOCK_SIZE - 1) / consts.BLOCK_SIZE * consts.BLOCK_SIZE
+	cipherLen := (len(cipherData) + consts.BLOCK_SIZE - 1) / consts.BLOCK_SIZE * consts.BLOCK_SIZE
 
-	paddedAuth := append(authData, authPadding...)
-	paddedCipher := append(cipherData, cipherPadding...)
-	totalLen := append(lenA, lenC...)
-	hashData := append(append(paddedAuth, paddedCipher...), totalLen...)
+	hashData := make([]byte, authLen+cipherLen+consts.BLOCK_SIZE)
+	copy(hashData, authData)
+	copy(hashData[authLen:], cipherData)
+	binary.BigEndian.PutUint64(hashData[authLen+cipherLen:], 8*uint64(len(authData)))
+	binary.BigEndian.PutUint64(hashData[authLen+cipherLen+8:], 8*uint64(len(cipherData)))
 
 	s := g.Ghash(hashData, hashSubKey)
-	tag, err := a.coreBlockCTR(s, nonce, preCtr)
+	tag, err := a.coreBlockCTR(s, preCtr)
 
 	if err != nil {
 		return nil, err
@@ -22,15 +22,22 @@ package aes256go
 
 import (
 	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"math"
 	"os"
 	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/wedkarz02/aes256go/src/consts"
+	"github.com/wedkarz02/aes256go/src/counter"
 	"github.com/wedkarz02/aes256go/src/key"
+	"github.com/wedkarz02/aes256go/src/padding"
 )
 
 func readTestFile(fileName string) ([][]byte, error) {
@@ -113,18 +120,7 @@ func TestEncryptBlock(t *testing.T) {
 	zeroState := make([]byte, consts.BLOCK_SIZE)
 
 	for i, testKey := range testKeys {
-		a, err := NewAES256(testKey)
-		if err != nil {
-			panic(err)
-		}
-
-		// The keys have to be reassigned here due to
-		// key hashing inside NewAES256().
-		// Otherwise the keys won't match the ones
-		// saved in test vector files -> test will fail.
-		// Please don't do this in the actual encryption.
-		a.Key = testKey
-		a.expandedKey, err = key.ExpandKey(a.Key)
+		a, err := NewAES256FromKey(testKey)
 		if err != nil {
 			panic(err)
 		}
@@ -158,29 +154,789 @@ func TestDecryptBlock(t *testing.T) {
 	expectedZeroState := make([]byte, consts.BLOCK_SIZE)
 
 	for i, testKey := range testKeys {
-		a, err := NewAES256(testKey)
+		a, err := NewAES256FromKey(testKey)
 		if err != nil {
 			panic(err)
 		}
 
-		// The keys have to be reassigned here due to
-		// key hashing inside NewAES256().
-		// Otherwise the keys won't match the ones
-		// saved in test vector files -> test will fail.
-		// Please don't do this in the actual encryption.
-		a.Key = testKey
-		a.expandedKey, err = key.ExpandKey(a.Key)
+		actualState, err := a.DecryptBlock(encryptedStates[i])
 		if err != nil {
 			panic(err)
 		}
 
-		actualState, err := a.DecryptBlock(encryptedStates[i])
+		if !reflect.DeepEqual(actualState, expectedZeroState) {
+			t.Fatalf("FAILED: block decryption failed")
+		}
+	}
+}
+
+func TestCipherBlockConformance(t *testing.T) {
+	a, err := NewAES256([]byte("cipher.Block conformance key"))
+	if err != nil {
+		panic(err)
+	}
+
+	ref, err := aes.NewCipher(a.Key)
+	if err != nil {
+		panic(err)
+	}
+
+	if a.BlockSize() != ref.BlockSize() {
+		t.Fatalf("FAILED: block size mismatch")
+	}
+
+	iv := []byte("0123456789abcdef")
+	plainText := bytes.Repeat([]byte("conformance test"), 8)
+
+	for _, size := range []int{0, 16, 48, 128} {
+		actual := make([]byte, size)
+		expected := make([]byte, size)
+
+		cipher.NewCBCEncrypter(a, iv).CryptBlocks(actual, plainText[:size])
+		cipher.NewCBCEncrypter(ref, iv).CryptBlocks(expected, plainText[:size])
+
+		if !bytes.Equal(actual, expected) {
+			t.Fatalf("FAILED: CBC encryption mismatch for %d bytes", size)
+		}
+
+		cipher.NewCBCDecrypter(a, iv).CryptBlocks(actual, actual)
+
+		if !bytes.Equal(actual, plainText[:size]) {
+			t.Fatalf("FAILED: CBC decryption mismatch for %d bytes", size)
+		}
+	}
+
+	for _, size := range []int{0, 1, 15, 16, 17, 100} {
+		actual := make([]byte, size)
+		expected := make([]byte, size)
+
+		cipher.NewCTR(a, iv).XORKeyStream(actual, plainText[:size])
+		cipher.NewCTR(ref, iv).XORKeyStream(expected, plainText[:size])
+
+		if !bytes.Equal(actual, expected) {
+			t.Fatalf("FAILED: CTR mismatch for %d bytes", size)
+		}
+	}
+
+	aGCM, err := cipher.NewGCM(a)
+	if err != nil {
+		panic(err)
+	}
+
+	refGCM, err := cipher.NewGCM(ref)
+	if err != nil {
+		panic(err)
+	}
+
+	nonce := iv[:aGCM.NonceSize()]
+	authData := []byte("additional data")
+
+	for _, size := range []int{0, 1, 16, 33, 128} {
+		actual := aGCM.Seal(nil, nonce, plainText[:size], authData)
+		expected := refGCM.Seal(nil, nonce, plainText[:size], authData)
+
+		if !bytes.Equal(actual, expected) {
+			t.Fatalf("FAILED: GCM seal mismatch for %d bytes", size)
+		}
+
+		opened, err := aGCM.Open(nil, nonce, actual, authData)
+		if err != nil || !bytes.Equal(opened, plainText[:size]) {
+			t.Fatalf("FAILED: GCM open mismatch for %d bytes", size)
+		}
+	}
+}
+
+func TestCipherBlockInPlace(t *testing.T) {
+	a, err := NewAES256([]byte("in place key"))
+	if err != nil {
+		panic(err)
+	}
+
+	block := []byte("sixteen byte blk")
+
+	expected, err := a.EncryptBlock(block)
+	if err != nil {
+		panic(err)
+	}
+
+	a.Encrypt(block, block)
+
+	if !bytes.Equal(block, expected) {
+		t.Fatalf("FAILED: in place encryption mismatch")
+	}
+
+	a.Decrypt(block, block)
+
+	if !bytes.Equal(block, []byte("sixteen byte blk")) {
+		t.Fatalf("FAILED: in place decryption mismatch")
+	}
+}
+
+func TestCipherBlockPanics(t *testing.T) {
+	a, err := NewAES256([]byte("panic key"))
+	if err != nil {
+		panic(err)
+	}
+
+	buf := make([]byte, 2*consts.BLOCK_SIZE)
+
+	cases := []struct {
+		name string
+		fn   func()
+	}{
+		{"short src", func() { a.Encrypt(buf, buf[:consts.BLOCK_SIZE-1]) }},
+		{"short dst", func() { a.Encrypt(buf[:consts.BLOCK_SIZE-1], buf) }},
+		{"overlap", func() { a.Encrypt(buf[1:], buf) }},
+		{"short src decrypt", func() { a.Decrypt(buf, buf[:consts.BLOCK_SIZE-1]) }},
+		{"short dst decrypt", func() { a.Decrypt(buf[:consts.BLOCK_SIZE-1], buf) }},
+		{"overlap decrypt", func() { a.Decrypt(buf, buf[1:]) }},
+	}
+
+	for _, c := range cases {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("FAILED: %s did not panic", c.name)
+				}
+			}()
+
+			c.fn()
+		}()
+	}
+}
+
+func TestNewAES256FromKey(t *testing.T) {
+	for _, size := range []int{0, 1, 15, 20, 31, 33, 64} {
+		if _, err := NewAES256FromKey(make([]byte, size)); err == nil {
+			t.Fatalf("FAILED: %d byte key accepted", size)
+		}
+	}
+
+	rawKey := []byte("0123456789abcdef0123456789abcdef")
+
+	a, err := NewAES256FromKey(rawKey)
+	if err != nil {
+		panic(err)
+	}
+
+	ref, err := aes.NewCipher(rawKey)
+	if err != nil {
+		panic(err)
+	}
+
+	plainBlock := []byte("raw key block 01")
+	expected := make([]byte, consts.BLOCK_SIZE)
+	ref.Encrypt(expected, plainBlock)
+
+	actual, err := a.EncryptBlock(plainBlock)
+	if err != nil {
+		panic(err)
+	}
+
+	if !bytes.Equal(actual, expected) {
+		t.Fatalf("FAILED: raw key encryption does not match crypto/aes")
+	}
+
+	// The cipher has to keep its own copy of the key,
+	// so wiping it must not touch the caller's slice.
+	a.ClearKey()
+
+	if !bytes.Equal(rawKey, []byte("0123456789abcdef0123456789abcdef")) {
+		t.Fatalf("FAILED: ClearKey modified the caller's key")
+	}
+}
+
+func newTestCounter(nonce []byte) *counter.Counter {
+	ctr, err := counter.NewNonceCounter(nonce)
+	if err != nil {
+		panic(err)
+	}
+
+	return ctr
+}
+
+func decodeHex(s string) []byte {
+	data, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		panic(err)
+	}
+
+	return data
+}
+
+// Example vectors from FIPS-197 Appendix A and Appendix C.
+var fips197Tests = []struct {
+	key        string
+	lastWord   string
+	plainText  string
+	cipherText string
+}{
+	{
+		key:        "000102030405060708090a0b0c0d0e0f",
+		plainText:  "00112233445566778899aabbccddeeff",
+		cipherText: "69c4e0d86a7b0430d8cdb78070b4c55a",
+	},
+	{
+		key:        "000102030405060708090a0b0c0d0e0f1011121314151617",
+		plainText:  "00112233445566778899aabbccddeeff",
+		cipherText: "dda97ca4864cdfe06eaf70a0ec0d7191",
+	},
+	{
+		key:        "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+		plainText:  "00112233445566778899aabbccddeeff",
+		cipherText: "8ea2b7ca516745bfeafc49904b496089",
+	},
+	{
+		key:      "2b7e151628aed2a6abf7158809cf4f3c",
+		lastWord: "b6630ca6",
+	},
+	{
+		key:      "8e73b0f7da0e6452c810f32b809079e562f8ead2522c6b7b",
+		lastWord: "01002202",
+	},
+	{
+		key:      "603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4",
+		lastWord: "706c631e",
+	},
+}
+
+func TestKeySizes(t *testing.T) {
+	for _, test := range fips197Tests {
+		testKey := decodeHex(test.key)
+
+		a, err := NewAES256FromKey(testKey)
+		if err != nil {
+			t.Fatalf("FAILED: %d byte key rejected: %v", len(testKey), err)
+		}
+
+		if test.lastWord != "" {
+			lastWord := a.expandedKey[len(a.expandedKey)-consts.WORD_SIZE:]
+
+			if !bytes.Equal(lastWord, decodeHex(test.lastWord)) {
+				t.Fatalf("FAILED: key expansion failed for %d byte key", len(testKey))
+			}
+
+			continue
+		}
+
+		actual, err := a.EncryptBlock(decodeHex(test.plainText))
 		if err != nil {
 			panic(err)
 		}
 
-		if !reflect.DeepEqual(actualState, expectedZeroState) {
-			t.Fatalf("FAILED: block decryption failed")
+		if !bytes.Equal(actual, decodeHex(test.cipherText)) {
+			t.Fatalf("FAILED: block encryption failed for %d byte key", len(testKey))
+		}
+
+		actual, err = a.DecryptBlock(actual)
+		if err != nil {
+			panic(err)
+		}
+
+		if !bytes.Equal(actual, decodeHex(test.plainText)) {
+			t.Fatalf("FAILED: block decryption failed for %d byte key", len(testKey))
 		}
 	}
 }
+
+func TestKeySizesModes(t *testing.T) {
+	plainText := []byte("Every mode of operation has to work with every key size.")
+	authData := []byte("authenticated only")
+	iv := []byte("0123456789abcdef")
+
+	for _, size := range []int{consts.KEY_SIZE_128, consts.KEY_SIZE_192, consts.KEY_SIZE_256} {
+		testKey := bytes.Repeat([]byte{byte(size)}, size)
+
+		a, err := NewAES256FromKey(testKey)
+		if err != nil {
+			panic(err)
+		}
+
+		ref, err := aes.NewCipher(testKey)
+		if err != nil {
+			panic(err)
+		}
+
+		paddedPlain := padding.PKCS7Padding(plainText)
+		actual := make([]byte, len(paddedPlain))
+		expected := make([]byte, len(paddedPlain))
+
+		cipher.NewCBCEncrypter(a, iv).CryptBlocks(actual, paddedPlain)
+		cipher.NewCBCEncrypter(ref, iv).CryptBlocks(expected, paddedPlain)
+
+		if !bytes.Equal(actual, expected) {
+			t.Fatalf("FAILED: %d byte key does not match crypto/aes", size)
+		}
+
+		cipherText, err := a.EncryptECB(plainText, padding.PKCS7Padding)
+		if err != nil {
+			panic(err)
+		}
+
+		decrypted, err := a.DecryptECB(cipherText, padding.PKCS7Unpadding)
+		if err != nil || !bytes.Equal(decrypted, plainText) {
+			t.Fatalf("FAILED: ECB round trip failed for %d byte key", size)
+		}
+
+		cipherText, err = a.EncryptCBC(plainText, padding.PKCS7Padding)
+		if err != nil {
+			panic(err)
+		}
+
+		decrypted, err = a.DecryptCBC(cipherText, padding.PKCS7Unpadding)
+		if err != nil || !bytes.Equal(decrypted, plainText) {
+			t.Fatalf("FAILED: CBC round trip failed for %d byte key", size)
+		}
+
+		cipherText, err = a.EncryptCFB(plainText, 5)
+		if err != nil {
+			panic(err)
+		}
+
+		decrypted, err = a.DecryptCFB(cipherText, 5)
+		if err != nil || !bytes.Equal(decrypted, plainText) {
+			t.Fatalf("FAILED: CFB round trip failed for %d byte key", size)
+		}
+
+		cipherText, err = a.EncryptOFB(plainText)
+		if err != nil {
+			panic(err)
+		}
+
+		decrypted, err = a.DecryptOFB(cipherText)
+		if err != nil || !bytes.Equal(decrypted, plainText) {
+			t.Fatalf("FAILED: OFB round trip failed for %d byte key", size)
+		}
+
+		cipherText, err = a.EncryptCTR(plainText)
+		if err != nil {
+			panic(err)
+		}
+
+		decrypted, err = a.DecryptCTR(cipherText)
+		if err != nil || !bytes.Equal(decrypted, plainText) {
+			t.Fatalf("FAILED: CTR round trip failed for %d byte key", size)
+		}
+
+		cipherText, err = a.EncryptGCM(plainText, authData)
+		if err != nil {
+			panic(err)
+		}
+
+		decrypted, err = a.DecryptGCM(cipherText, authData)
+		if err != nil || !bytes.Equal(decrypted, plainText) {
+			t.Fatalf("FAILED: GCM round trip failed for %d byte key", size)
+		}
+	}
+}
+
+// AES-256 test cases 13-16 from "The Galois/Counter Mode of
+// Operation (GCM)" by McGrew and Viega. Test cases 17 and 18
+// use nonces of other sizes than 96 bits, which this package
+// does not support.
+var gcmTests = []struct {
+	key        string
+	nonce      string
+	plainText  string
+	authData   string
+	cipherText string
+	tag        string
+}{
+	{
+		key:   "0000000000000000000000000000000000000000000000000000000000000000",
+		nonce: "000000000000000000000000",
+		tag:   "530f8afbc74536b9a963b4f1c4cb738b",
+	},
+	{
+		key:        "0000000000000000000000000000000000000000000000000000000000000000",
+		nonce:      "000000000000000000000000",
+		plainText:  "00000000000000000000000000000000",
+		cipherText: "cea7403d4d606b6e074ec5d3baf39d18",
+		tag:        "d0d1c8a799996bf0265b98b5d48ab919",
+	},
+	{
+		key:   "feffe9928665731c6d6a8f9467308308feffe9928665731c6d6a8f9467308308",
+		nonce: "cafebabefacedbaddecaf888",
+		plainText: "d9313225f88406e5a55909c5aff5269a86a7a9531534f7da2e4c303d8a318a72" +
+			"1c3c0c95956809532fcf0e2449a6b525b16aedf5aa0de657ba637b391aafd255",
+		cipherText: "522dc1f099567d07f47f37a32a84427d643a8cdcbfe5c0c97598a2bd2555d1aa" +
+			"8cb08e48590dbb3da7b08b1056828838c5f61e6393ba7a0abcc9f662898015ad",
+		tag: "b094dac5d93471bdec1a502270e3cc6c",
+	},
+	{
+		key:   "feffe9928665731c6d6a8f9467308308feffe9928665731c6d6a8f9467308308",
+		nonce: "cafebabefacedbaddecaf888",
+		plainText: "d9313225f88406e5a55909c5aff5269a86a7a9531534f7da2e4c303d8a318a72" +
+			"1c3c0c95956809532fcf0e2449a6b525b16aedf5aa0de657ba637b39",
+		authData: "feedfacedeadbeeffeedfacedeadbeefabaddad2",
+		cipherText: "522dc1f099567d07f47f37a32a84427d643a8cdcbfe5c0c97598a2bd2555d1aa" +
+			"8cb08e48590dbb3da7b08b1056828838c5f61e6393ba7a0abcc9f662",
+		tag: "76fc6ece0f4e1768cddf8853bb2d551b",
+	},
+}
+
+func TestGCMVectors(t *testing.T) {
+	for i, test := range gcmTests {
+		a, err := NewAES256FromKey(decodeHex(test.key))
+		if err != nil {
+			panic(err)
+		}
+
+		aead, err := a.NewGCM()
+		if err != nil {
+			panic(err)
+		}
+
+		nonce := decodeHex(test.nonce)
+		expected := append(append(nonce, decodeHex(test.cipherText)...), decodeHex(test.tag)...)
+		actual := aead.Seal(nonce, nonce, decodeHex(test.plainText), decodeHex(test.authData))
+
+		if !bytes.Equal(actual, expected) {
+			t.Fatalf("FAILED: GCM test case %d encryption failed", 13+i)
+		}
+
+		actual, err = a.EncryptGCMWithNonce(nonce, decodeHex(test.plainText), decodeHex(test.authData))
+		if err != nil || !bytes.Equal(actual, expected) {
+			t.Fatalf("FAILED: GCM test case %d encryption with an explicit nonce failed", 13+i)
+		}
+
+		plainText, err := a.DecryptGCM(expected, decodeHex(test.authData))
+		if err != nil || !bytes.Equal(plainText, decodeHex(test.plainText)) {
+			t.Fatalf("FAILED: GCM test case %d decryption failed", 13+i)
+		}
+
+		expected[len(expected)-1] ^= 0x01
+
+		if _, err := a.DecryptGCM(expected, decodeHex(test.authData)); err == nil {
+			t.Fatalf("FAILED: GCM test case %d accepted a forged tag", 13+i)
+		}
+	}
+}
+
+func TestGCMInterop(t *testing.T) {
+	a, err := NewAES256([]byte("GCM interop key"))
+	if err != nil {
+		panic(err)
+	}
+
+	ref, err := aes.NewCipher(a.Key)
+	if err != nil {
+		panic(err)
+	}
+
+	refGCM, err := cipher.NewGCM(ref)
+	if err != nil {
+		panic(err)
+	}
+
+	plainText := bytes.Repeat([]byte("interoperability"), 5)
+	authData := []byte("header")
+
+	for _, size := range []int{0, 1, 15, 16, 17, 64, 80} {
+		cipherText, err := a.EncryptGCM(plainText[:size], authData[:size%len(authData)])
+		if err != nil {
+			panic(err)
+		}
+
+		nonce := cipherText[:consts.NONCE_SIZE]
+
+		opened, err := refGCM.Open(nil, nonce, cipherText[consts.NONCE_SIZE:], authData[:size%len(authData)])
+		if err != nil || !bytes.Equal(opened, plainText[:size]) {
+			t.Fatalf("FAILED: crypto/cipher could not open %d byte GCM message", size)
+		}
+
+		sealed := refGCM.Seal(append([]byte{}, nonce...), nonce, plainText[:size], authData[:size%len(authData)])
+
+		decrypted, err := a.DecryptGCM(sealed, authData[:size%len(authData)])
+		if err != nil || !bytes.Equal(decrypted, plainText[:size]) {
+			t.Fatalf("FAILED: could not open %d byte GCM message from crypto/cipher", size)
+		}
+	}
+}
+
+// AES-256 example vectors from NIST SP 800-38A Appendix F.
+const (
+	sp80038aKey       = "603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4"
+	sp80038aIV        = "000102030405060708090a0b0c0d0e0f"
+	sp80038aPlainText = "6bc1bee22e409f96e93d7e117393172a ae2d8a571e03ac9c9eb76fac45af8e51" +
+		"30c81c46a35ce411e5fbc1191a0a52ef f69f2445df4f9b17ad2b417be66c3710"
+)
+
+var sp80038aTests = []struct {
+	mode       string
+	cipherText string
+}{
+	{
+		mode: "CBC",
+		cipherText: "f58c4c04d6e5f1ba779eabfb5f7bfbd6 9cfc4e967edb808d679f777bc6702c7d" +
+			"39f23369a9d9bacfa530e26304231461 b2eb05e2c39be9fcda6c19078c6a9d1b",
+	},
+	{
+		mode: "CFB",
+		cipherText: "dc7e84bfda79164b7ecd8486985d3860 39ffed143b28b1c832113c6331e5407b" +
+			"df10132415e54b92a13ed0a8267ae2f9 75a385741ab9cef82031623d55b1e471",
+	},
+	{
+		mode: "OFB",
+		cipherText: "dc7e84bfda79164b7ecd8486985d3860 4febdc6740d20b3ac88f6ad82a4fb08d" +
+			"71ab47a086e86eedf39d1c5bba97c408 0126141d67f37be8538f5a8be740e484",
+	},
+}
+
+func TestSP80038AVectors(t *testing.T) {
+	a, err := NewAES256FromKey(decodeHex(sp80038aKey))
+	if err != nil {
+		panic(err)
+	}
+
+	iv := decodeHex(sp80038aIV)
+	plainText := decodeHex(sp80038aPlainText)
+
+	// The vectors are block aligned, so no padding is applied.
+	noPad := func(data []byte) []byte { return data }
+	noUnpad := func(data []byte) ([]byte, error) { return data, nil }
+
+	for _, test := range sp80038aTests {
+		var actual, decrypted []byte
+
+		expected := append(decodeHex(sp80038aIV), decodeHex(test.cipherText)...)
+
+		switch test.mode {
+		case "CBC":
+			actual, err = a.EncryptCBCWithIV(iv, plainText, noPad)
+			if err == nil {
+				decrypted, err = a.DecryptCBC(expected, noUnpad)
+			}
+		case "CFB":
+			actual, err = a.EncryptCFBWithIV(iv, plainText, consts.BLOCK_SIZE)
+			if err == nil {
+				decrypted, err = a.DecryptCFB(expected, consts.BLOCK_SIZE)
+			}
+		case "OFB":
+			actual, err = a.EncryptOFBWithIV(iv, plainText)
+			if err == nil {
+				decrypted, err = a.DecryptOFB(expected)
+			}
+		}
+
+		if err != nil {
+			t.Fatalf("FAILED: %s-AES256 returned an error: %v", test.mode, err)
+		}
+
+		if !bytes.Equal(actual, expected) {
+			t.Fatalf("FAILED: %s-AES256 encryption failed", test.mode)
+		}
+
+		if !bytes.Equal(decrypted, plainText) {
+			t.Fatalf("FAILED: %s-AES256 decryption failed", test.mode)
+		}
+
+		if !bytes.Equal(iv, decodeHex(sp80038aIV)) {
+			t.Fatalf("FAILED: %s-AES256 modified the caller's iv", test.mode)
+		}
+	}
+}
+
+func TestSP80038AVectorsCTR(t *testing.T) {
+	a, err := NewAES256FromKey(decodeHex(sp80038aKey))
+	if err != nil {
+		panic(err)
+	}
+
+	// The initial counter block f0f1...feff interpreted as a
+	// 12 byte nonce with a 32 bit counter and as a full 128 bit
+	// counter gives the same result for the first blocks.
+	counterBlock := decodeHex("f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff")
+	nonce := counterBlock[:consts.NONCE_SIZE]
+
+	expected := decodeHex("601ec313775789a5b7a7f504bbf3d228 f443e3ca4d62b59aca84e990cacaf5c5" +
+		"2b0930daa23de94ce87017ba2d84988d dfc9c58db67aada613c2dd08457941a6")
+
+	var actual []byte
+
+	for _, width := range []int{consts.COUNTER_SIZE, 8, consts.BLOCK_SIZE} {
+		cipherText, err := a.EncryptCTRWithCounter(counterBlock, width, decodeHex(sp80038aPlainText))
+		if err != nil || !bytes.Equal(cipherText, append(decodeHex("f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff"), expected...)) {
+			t.Fatalf("FAILED: CTR-AES256 encryption failed with a %d byte counter", width)
+		}
+
+		decrypted, err := a.DecryptCTRWithCounter(cipherText, width)
+		if err != nil || !bytes.Equal(decrypted, decodeHex(sp80038aPlainText)) {
+			t.Fatalf("FAILED: CTR-AES256 decryption failed with a %d byte counter", width)
+		}
+
+		actual = cipherText[consts.BLOCK_SIZE:]
+	}
+
+	// With the counter starting at 0 the explicit nonce
+	// variant has to agree with the random one.
+	cipherText, err := a.EncryptCTRWithNonce(nonce, actual)
+	if err != nil {
+		panic(err)
+	}
+
+	if !bytes.Equal(cipherText[:consts.NONCE_SIZE], nonce) {
+		t.Fatalf("FAILED: CTR nonce was not prepended")
+	}
+
+	decrypted, err := a.DecryptCTR(cipherText)
+	if err != nil || !bytes.Equal(decrypted, actual) {
+		t.Fatalf("FAILED: CTR round trip with an explicit nonce failed")
+	}
+}
+
+func TestExplicitIVErrors(t *testing.T) {
+	a, err := NewAES256FromKey(decodeHex(sp80038aKey))
+	if err != nil {
+		panic(err)
+	}
+
+	plainText := []byte("explicit iv")
+
+	for _, size := range []int{0, consts.IV_SIZE - 1, consts.IV_SIZE + 1} {
+		iv := make([]byte, size)
+
+		if _, err := a.EncryptCBCWithIV(iv, plainText, padding.PKCS7Padding); err == nil {
+			t.Fatalf("FAILED: CBC accepted a %d byte iv", size)
+		}
+
+		if _, err := a.EncryptCFBWithIV(iv, plainText, 1); err == nil {
+			t.Fatalf("FAILED: CFB accepted a %d byte iv", size)
+		}
+
+		if _, err := a.EncryptOFBWithIV(iv, plainText); err == nil {
+			t.Fatalf("FAILED: OFB accepted a %d byte iv", size)
+		}
+	}
+
+	for _, size := range []int{0, consts.NONCE_SIZE - 1, consts.NONCE_SIZE + 1} {
+		nonce := make([]byte, size)
+
+		if _, err := a.EncryptCTRWithNonce(nonce, plainText); err == nil {
+			t.Fatalf("FAILED: CTR accepted a %d byte nonce", size)
+		}
+
+		if _, err := a.EncryptGCMWithNonce(nonce, plainText, nil); err == nil {
+			t.Fatalf("FAILED: GCM accepted a %d byte nonce", size)
+		}
+	}
+}
+
+func TestCTRCounterWidths(t *testing.T) {
+	a, err := NewAES256FromKey(decodeHex(sp80038aKey))
+	if err != nil {
+		panic(err)
+	}
+
+	ref, err := aes.NewCipher(decodeHex(sp80038aKey))
+	if err != nil {
+		panic(err)
+	}
+
+	plainText := bytes.Repeat([]byte("carry propagation"), 10)
+
+	// crypto/cipher increments the whole counter block, so
+	// a carry out of the low 64 bits has to match it as well.
+	counterBlock := decodeHex("0011223344556677 fffffffffffffffe")
+	expected := make([]byte, len(plainText))
+	cipher.NewCTR(ref, counterBlock).XORKeyStream(expected, plainText)
+
+	cipherText, err := a.EncryptCTRWithCounter(counterBlock, consts.BLOCK_SIZE, plainText)
+	if err != nil || !bytes.Equal(cipherText[consts.BLOCK_SIZE:], expected) {
+		t.Fatalf("FAILED: 128 bit CTR does not match crypto/cipher")
+	}
+
+	stream, err := a.NewCTRStreamWithCounter(counterBlock, consts.BLOCK_SIZE)
+	if err != nil {
+		panic(err)
+	}
+
+	actual := make([]byte, len(plainText))
+	stream.XORKeyStream(actual[:20], plainText[:20])
+	stream.XORKeyStream(actual[20:], plainText[20:])
+
+	if !bytes.Equal(actual, expected) {
+		t.Fatalf("FAILED: 128 bit CTR stream does not match crypto/cipher")
+	}
+
+	// A 64 bit counter has room for two blocks only.
+	if _, err := a.EncryptCTRWithCounter(counterBlock, 8, plainText[:32]); err != nil {
+		t.Fatalf("FAILED: 64 bit CTR rejected the last two blocks: %v", err)
+	}
+
+	if _, err := a.EncryptCTRWithCounter(counterBlock, 8, plainText[:33]); !errors.Is(err, counter.ErrOverflow) {
+		t.Fatalf("FAILED: 64 bit CTR did not report the overflow, got %v", err)
+	}
+
+	for _, width := range []int{0, consts.BLOCK_SIZE + 1} {
+		if _, err := a.EncryptCTRWithCounter(counterBlock, width, plainText); err == nil {
+			t.Fatalf("FAILED: %d byte counter accepted", width)
+		}
+	}
+
+	if _, err := a.EncryptCTRWithCounter(counterBlock[1:], consts.COUNTER_SIZE, plainText); err == nil {
+		t.Fatalf("FAILED: short counter block accepted")
+	}
+
+	if _, err := a.DecryptCTRWithCounter(counterBlock[1:], consts.COUNTER_SIZE); err == nil {
+		t.Fatalf("FAILED: cipherText without a counter block accepted")
+	}
+}
+
+func TestCTRCounterOverflow(t *testing.T) {
+	a, err := NewAES256FromKey(decodeHex(sp80038aKey))
+	if err != nil {
+		panic(err)
+	}
+
+	if remaining := counter.NewCounter().Remaining(); remaining != 1<<32 {
+		t.Fatalf("FAILED: 32 bit counter has %d blocks", remaining)
+	}
+
+	legacy := counter.NewCounter()
+	legacy.Increment()
+
+	if !bytes.Equal(legacy.Bytes(), []byte{0x00, 0x00, 0x00, 0x01}) {
+		t.Fatalf("FAILED: counter bytes do not hold the incremented counter")
+	}
+
+	if remaining := newTestCounter(nil).Remaining(); remaining != math.MaxUint64 {
+		t.Fatalf("FAILED: 128 bit counter does not saturate, got %d", remaining)
+	}
+
+	// Two blocks are left before the 32 bit counter wraps.
+	counterBlock := decodeHex("000000000000000000000000 fffffffe")
+
+	if _, err := a.EncryptCTRWithCounter(counterBlock, consts.COUNTER_SIZE, make([]byte, 32)); err != nil {
+		t.Fatalf("FAILED: CTR rejected the last two blocks: %v", err)
+	}
+
+	if _, err := a.EncryptCTRWithCounter(counterBlock, consts.COUNTER_SIZE, make([]byte, 33)); !errors.Is(err, counter.ErrOverflow) {
+		t.Fatalf("FAILED: CTR did not report the overflow, got %v", err)
+	}
+
+	ctr, err := counter.NewCounterBlock(counterBlock, consts.COUNTER_SIZE)
+	if err != nil {
+		panic(err)
+	}
+
+	if ctr.Increment() != nil || ctr.Increment() != counter.ErrOverflow || ctr.Remaining() != 0 {
+		t.Fatalf("FAILED: counter did not report the wrap")
+	}
+
+	stream, err := a.NewCTRStreamWithCounter(counterBlock, consts.COUNTER_SIZE)
+	if err != nil {
+		panic(err)
+	}
+
+	buf := make([]byte, 32)
+	stream.XORKeyStream(buf, buf)
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("FAILED: CTR stream did not panic on overflow")
+		}
+	}()
+
+	stream.XORKeyStream(buf[:1], buf[:1])
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//go:build amd64 && !purego
+
+package aes256go
+
+import (
+	"os"
+	"strings"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+)
+
+//go:noescape
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+
+//go:noescape
+func expandKeyAsm(nr int, key *byte, enc *byte, dec *byte)
+
+//go:noescape
+func encryptBlocksAsm(nr int, xk *byte, dst *byte, src *byte, n int)
+
+//go:noescape
+func decryptBlocksAsm(nr int, xk *byte, dst *byte, src *byte, n int)
+
+// SupportsAESNI reports whether the CPU implements the AES
+// instructions and they haven't been disabled with
+// GODEBUG=cpu.aes=off, like in the standard library.
+var supportsAESNI = detectAESNI()
+
+func detectAESNI() bool {
+	for _, setting := range strings.Split(os.Getenv("GODEBUG"), ",") {
+		if setting == "cpu.aes=off" || setting == "cpu.all=off" {
+			return false
+		}
+	}
+
+	maxID, _, _, _ := cpuid(0, 0)
+	if maxID < 1 {
+		return false
+	}
+
+	_, _, ecx, _ := cpuid(1, 0)
+	return ecx&(1<<25) != 0
+}
+
+// ExpandKeyNI calculates the round keys with AESKEYGENASSIST
+// and the decryption round keys with AESIMC.
+func expandKeyNI(k []byte, rounds int) ([]byte, []byte) {
+	enc := make([]byte, (rounds+1)*consts.BLOCK_SIZE)
+	dec := make([]byte, len(enc))
+	expandKeyAsm(rounds, &k[0], &enc[0], &dec[0])
+
+	return enc, dec
+}
+
+func encryptBlocksNI(rounds int, xk []byte, dst, src []byte) {
+	if len(src) == 0 {
+		return
+	}
+
+	encryptBlocksAsm(rounds, &xk[0], &dst[0], &src[0], len(src)/consts.BLOCK_SIZE)
+}
+
+func decryptBlocksNI(rounds int, xk []byte, dst, src []byte) {
+	if len(src) == 0 {
+		return
+	}
+
+	decryptBlocksAsm(rounds, &xk[0], &dst[0], &src[0], len(src)/consts.BLOCK_SIZE)
+}
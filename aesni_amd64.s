@@ -0,0 +1,392 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//go:build amd64 && !purego
+
+#include "textflag.h"
+
+// The key expansion follows the Intel AES-NI white paper:
+// https://www.intel.com/content/dam/doc/white-paper/advanced-encryption-standard-new-instructions-set-paper.pdf
+
+// func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+TEXT ·cpuid(SB), NOSPLIT, $0-24
+	MOVL eaxArg+0(FP), AX
+	MOVL ecxArg+4(FP), CX
+	CPUID
+	MOVL AX, eax+8(FP)
+	MOVL BX, ebx+12(FP)
+	MOVL CX, ecx+16(FP)
+	MOVL DX, edx+20(FP)
+	RET
+
+// func expandKeyAsm(nr int, key *byte, enc *byte, dec *byte)
+TEXT ·expandKeyAsm(SB), NOSPLIT, $0-32
+	MOVQ nr+0(FP), CX
+	MOVQ key+8(FP), AX
+	MOVQ enc+16(FP), BX
+	MOVQ dec+24(FP), DX
+
+	MOVUPS (AX), X0
+	MOVUPS X0, (BX)
+	ADDQ   $16, BX
+	PXOR   X4, X4
+
+	CMPQ CX, $12
+	JE   expand192
+	JB   expand128
+
+	MOVUPS 16(AX), X2
+	MOVUPS X2, (BX)
+	ADDQ   $16, BX
+	AESKEYGENASSIST $0x01, X2, X1
+	CALL expandKey256a<>(SB)
+	AESKEYGENASSIST $0x01, X0, X1
+	CALL expandKey256b<>(SB)
+	AESKEYGENASSIST $0x02, X2, X1
+	CALL expandKey256a<>(SB)
+	AESKEYGENASSIST $0x02, X0, X1
+	CALL expandKey256b<>(SB)
+	AESKEYGENASSIST $0x04, X2, X1
+	CALL expandKey256a<>(SB)
+	AESKEYGENASSIST $0x04, X0, X1
+	CALL expandKey256b<>(SB)
+	AESKEYGENASSIST $0x08, X2, X1
+	CALL expandKey256a<>(SB)
+	AESKEYGENASSIST $0x08, X0, X1
+	CALL expandKey256b<>(SB)
+	AESKEYGENASSIST $0x10, X2, X1
+	CALL expandKey256a<>(SB)
+	AESKEYGENASSIST $0x10, X0, X1
+	CALL expandKey256b<>(SB)
+	AESKEYGENASSIST $0x20, X2, X1
+	CALL expandKey256a<>(SB)
+	AESKEYGENASSIST $0x20, X0, X1
+	CALL expandKey256b<>(SB)
+	AESKEYGENASSIST $0x40, X2, X1
+	CALL expandKey256a<>(SB)
+	JMP  invertKeys
+
+expand192:
+	MOVQ 16(AX), X2
+	AESKEYGENASSIST $0x01, X2, X1
+	CALL expandKey192a<>(SB)
+	AESKEYGENASSIST $0x02, X2, X1
+	CALL expandKey192b<>(SB)
+	AESKEYGENASSIST $0x04, X2, X1
+	CALL expandKey192a<>(SB)
+	AESKEYGENASSIST $0x08, X2, X1
+	CALL expandKey192b<>(SB)
+	AESKEYGENASSIST $0x10, X2, X1
+	CALL expandKey192a<>(SB)
+	AESKEYGENASSIST $0x20, X2, X1
+	CALL expandKey192b<>(SB)
+	AESKEYGENASSIST $0x40, X2, X1
+	CALL expandKey192a<>(SB)
+	AESKEYGENASSIST $0x80, X2, X1
+	CALL expandKey192b<>(SB)
+	JMP  invertKeys
+
+expand128:
+	AESKEYGENASSIST $0x01, X0, X1
+	CALL expandKey128<>(SB)
+	AESKEYGENASSIST $0x02, X0, X1
+	CALL expandKey128<>(SB)
+	AESKEYGENASSIST $0x04, X0, X1
+	CALL expandKey128<>(SB)
+	AESKEYGENASSIST $0x08, X0, X1
+	CALL expandKey128<>(SB)
+	AESKEYGENASSIST $0x10, X0, X1
+	CALL expandKey128<>(SB)
+	AESKEYGENASSIST $0x20, X0, X1
+	CALL expandKey128<>(SB)
+	AESKEYGENASSIST $0x40, X0, X1
+	CALL expandKey128<>(SB)
+	AESKEYGENASSIST $0x80, X0, X1
+	CALL expandKey128<>(SB)
+	AESKEYGENASSIST $0x1b, X0, X1
+	CALL expandKey128<>(SB)
+	AESKEYGENASSIST $0x36, X0, X1
+	CALL expandKey128<>(SB)
+
+	// Decryption keys are the encryption keys in the reverse
+	// order with InvMixColumns applied to the inner rounds.
+invertKeys:
+	SUBQ   $16, BX
+	MOVUPS (BX), X1
+	MOVUPS X1, (DX)
+	ADDQ   $16, DX
+	DECQ   CX
+
+invertLoop:
+	SUBQ   $16, BX
+	MOVUPS (BX), X1
+	AESIMC X1, X0
+	MOVUPS X0, (DX)
+	ADDQ   $16, DX
+	DECQ   CX
+	JNZ    invertLoop
+
+	SUBQ   $16, BX
+	MOVUPS (BX), X0
+	MOVUPS X0, (DX)
+	RET
+
+// Expects the previous round key in X0, the AESKEYGENASSIST
+// result in X1 and zero in X4.
+TEXT expandKey128<>(SB), NOSPLIT, $0
+	PSHUFD $0xff, X1, X1
+	SHUFPS $0x10, X0, X4
+	PXOR   X4, X0
+	SHUFPS $0x8c, X0, X4
+	PXOR   X4, X0
+	PXOR   X1, X0
+	MOVUPS X0, (BX)
+	ADDQ   $16, BX
+	RET
+
+// Expects the words 0-3 of the last six in X0, words 4-5
+// in the low half of X2, the AESKEYGENASSIST result in X1
+// and zero in X4. Stores one and a half round keys.
+TEXT expandKey192a<>(SB), NOSPLIT, $0
+	PSHUFD $0x55, X1, X1
+	SHUFPS $0x10, X0, X4
+	PXOR   X4, X0
+	SHUFPS $0x8c, X0, X4
+	PXOR   X4, X0
+	PXOR   X1, X0
+
+	MOVAPS X2, X5
+	MOVAPS X2, X6
+	PSLLDQ $4, X5
+	PSHUFD $0xff, X0, X3
+	PXOR   X3, X2
+	PXOR   X5, X2
+
+	MOVAPS X0, X1
+	SHUFPS $0x44, X0, X6
+	MOVUPS X6, (BX)
+	SHUFPS $0x4e, X2, X1
+	MOVUPS X1, 16(BX)
+	ADDQ   $32, BX
+	RET
+
+// Same as expandKey192a, but the two remaining words are
+// already aligned, so a single round key is stored.
+TEXT expandKey192b<>(SB), NOSPLIT, $0
+	PSHUFD $0x55, X1, X1
+	SHUFPS $0x10, X0, X4
+	PXOR   X4, X0
+	SHUFPS $0x8c, X0, X4
+	PXOR   X4, X0
+	PXOR   X1, X0
+
+	MOVAPS X2, X5
+	PSLLDQ $4, X5
+	PSHUFD $0xff, X0, X3
+	PXOR   X3, X2
+	PXOR   X5, X2
+
+	MOVUPS X0, (BX)
+	ADDQ   $16, BX
+	RET
+
+// Even round keys of the 256 bit schedule are computed
+// exactly like the 128 bit ones.
+TEXT expandKey256a<>(SB), NOSPLIT, $0
+	JMP expandKey128<>(SB)
+
+// Odd round keys use SubWord without RotWord and Rcon,
+// which is the third word of the AESKEYGENASSIST result.
+TEXT expandKey256b<>(SB), NOSPLIT, $0
+	PSHUFD $0xaa, X1, X1
+	SHUFPS $0x10, X2, X4
+	PXOR   X4, X2
+	SHUFPS $0x8c, X2, X4
+	PXOR   X4, X2
+	PXOR   X1, X2
+	MOVUPS X2, (BX)
+	ADDQ   $16, BX
+	RET
+
+// func encryptBlocksAsm(nr int, xk *byte, dst *byte, src *byte, n int)
+TEXT ·encryptBlocksAsm(SB), NOSPLIT, $0-40
+	MOVQ nr+0(FP), CX
+	MOVQ xk+8(FP), AX
+	MOVQ dst+16(FP), DX
+	MOVQ src+24(FP), BX
+	MOVQ n+32(FP), R8
+
+	// Four blocks are interleaved to hide
+	// the latency of AESENC.
+encLoop4:
+	CMPQ R8, $4
+	JB   encLoop1
+
+	MOVUPS (AX), X4
+	MOVUPS 0(BX), X0
+	MOVUPS 16(BX), X1
+	MOVUPS 32(BX), X2
+	MOVUPS 48(BX), X3
+	PXOR   X4, X0
+	PXOR   X4, X1
+	PXOR   X4, X2
+	PXOR   X4, X3
+
+	MOVQ AX, R9
+	MOVQ CX, R10
+	DECQ R10
+
+encRound4:
+	ADDQ   $16, R9
+	MOVUPS (R9), X4
+	AESENC X4, X0
+	AESENC X4, X1
+	AESENC X4, X2
+	AESENC X4, X3
+	DECQ   R10
+	JNZ    encRound4
+
+	MOVUPS     16(R9), X4
+	AESENCLAST X4, X0
+	AESENCLAST X4, X1
+	AESENCLAST X4, X2
+	AESENCLAST X4, X3
+	MOVUPS     X0, 0(DX)
+	MOVUPS     X1, 16(DX)
+	MOVUPS     X2, 32(DX)
+	MOVUPS     X3, 48(DX)
+
+	ADDQ $64, BX
+	ADDQ $64, DX
+	SUBQ $4, R8
+	JMP  encLoop4
+
+encLoop1:
+	TESTQ R8, R8
+	JZ    encDone
+
+	MOVUPS (AX), X4
+	MOVUPS (BX), X0
+	PXOR   X4, X0
+
+	MOVQ AX, R9
+	MOVQ CX, R10
+	DECQ R10
+
+encRound1:
+	ADDQ   $16, R9
+	MOVUPS (R9), X4
+	AESENC X4, X0
+	DECQ   R10
+	JNZ    encRound1
+
+	MOVUPS     16(R9), X4
+	AESENCLAST X4, X0
+	MOVUPS     X0, (DX)
+
+	ADDQ $16, BX
+	ADDQ $16, DX
+	DECQ R8
+	JMP  encLoop1
+
+encDone:
+	RET
+
+// func decryptBlocksAsm(nr int, xk *byte, dst *byte, src *byte, n int)
+TEXT ·decryptBlocksAsm(SB), NOSPLIT, $0-40
+	MOVQ nr+0(FP), CX
+	MOVQ xk+8(FP), AX
+	MOVQ dst+16(FP), DX
+	MOVQ src+24(FP), BX
+	MOVQ n+32(FP), R8
+
+decLoop4:
+	CMPQ R8, $4
+	JB   decLoop1
+
+	MOVUPS (AX), X4
+	MOVUPS 0(BX), X0
+	MOVUPS 16(BX), X1
+	MOVUPS 32(BX), X2
+	MOVUPS 48(BX), X3
+	PXOR   X4, X0
+	PXOR   X4, X1
+	PXOR   X4, X2
+	PXOR   X4, X3
+
+	MOVQ AX, R9
+	MOVQ CX, R10
+	DECQ R10
+
+decRound4:
+	ADDQ   $16, R9
+	MOVUPS (R9), X4
+	AESDEC X4, X0
+	AESDEC X4, X1
+	AESDEC X4, X2
+	AESDEC X4, X3
+	DECQ   R10
+	JNZ    decRound4
+
+	MOVUPS     16(R9), X4
+	AESDECLAST X4, X0
+	AESDECLAST X4, X1
+	AESDECLAST X4, X2
+	AESDECLAST X4, X3
+	MOVUPS     X0, 0(DX)
+	MOVUPS     X1, 16(DX)
+	MOVUPS     X2, 32(DX)
+	MOVUPS     X3, 48(DX)
+
+	ADDQ $64, BX
+	ADDQ $64, DX
+	SUBQ $4, R8
+	JMP  decLoop4
+
+decLoop1:
+	TESTQ R8, R8
+	JZ    decDone
+
+	MOVUPS (AX), X4
+	MOVUPS (BX), X0
+	PXOR   X4, X0
+
+	MOVQ AX, R9
+	MOVQ CX, R10
+	DECQ R10
+
+decRound1:
+	ADDQ   $16, R9
+	MOVUPS (R9), X4
+	AESDEC X4, X0
+	DECQ   R10
+	JNZ    decRound1
+
+	MOVUPS     16(R9), X4
+	AESDECLAST X4, X0
+	MOVUPS     X0, (DX)
+
+	ADDQ $16, BX
+	ADDQ $16, DX
+	DECQ R8
+	JMP  decLoop1
+
+decDone:
+	RET
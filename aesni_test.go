@@ -0,0 +1,258 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"testing"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+)
+
+// AvailableCores lists every block cipher core
+// which can run on the current machine.
+func availableCores() []Core {
+	cores := []Core{CoreTable, CoreBitsliced}
+
+	if supportsAESNI {
+		cores = append(cores, CoreAESNI)
+	}
+
+	return cores
+}
+
+func TestDefaultCore(t *testing.T) {
+	a, err := NewAES256([]byte("default core key"))
+	if err != nil {
+		panic(err)
+	}
+
+	if supportsAESNI && a.core != CoreAESNI {
+		t.Fatalf("FAILED: AES-NI is supported but not used by default")
+	}
+
+	if !supportsAESNI && a.core != CoreTable {
+		t.Fatalf("FAILED: table core is not the default fallback")
+	}
+
+	if !supportsAESNI {
+		if _, err := NewAES256([]byte("key"), WithCore(CoreAESNI)); err == nil {
+			t.Fatalf("FAILED: AES-NI selected on unsupported CPU")
+		}
+	}
+}
+
+func TestAESNIKeyExpansion(t *testing.T) {
+	if !supportsAESNI {
+		t.Skip("AES-NI not supported")
+	}
+
+	for _, test := range fips197Tests {
+		testKey := decodeHex(test.key)
+
+		ni, err := NewAES256FromKey(testKey, WithCore(CoreAESNI))
+		if err != nil {
+			panic(err)
+		}
+
+		table, err := NewAES256FromKey(testKey, WithCore(CoreTable))
+		if err != nil {
+			panic(err)
+		}
+
+		if !bytes.Equal(ni.niEncKey, table.expandedKey) {
+			t.Fatalf("FAILED: AESKEYGENASSIST key expansion mismatch for %d byte key", len(testKey))
+		}
+
+		decKey := make([]byte, len(table.decKey)*consts.WORD_SIZE)
+		for i, w := range table.decKey {
+			binary.BigEndian.PutUint32(decKey[consts.WORD_SIZE*i:], w)
+		}
+
+		if !bytes.Equal(ni.niDecKey, decKey) {
+			t.Fatalf("FAILED: AESIMC decryption keys mismatch for %d byte key", len(testKey))
+		}
+	}
+}
+
+func TestCoresVectors(t *testing.T) {
+	testKeys, err := readTestFile("test/testvec/blockkey-test.txt")
+	if err != nil {
+		panic(err)
+	}
+
+	encryptedStates, err := readTestFile("test/testvec/blockenc-test.txt")
+	if err != nil {
+		panic(err)
+	}
+
+	zeroState := make([]byte, consts.BLOCK_SIZE)
+
+	for _, core := range availableCores() {
+		for _, test := range fips197Tests {
+			if test.plainText == "" {
+				continue
+			}
+
+			a, err := NewAES256FromKey(decodeHex(test.key), WithCore(core))
+			if err != nil {
+				panic(err)
+			}
+
+			actual, err := a.EncryptBlock(decodeHex(test.plainText))
+			if err != nil {
+				panic(err)
+			}
+
+			if !bytes.Equal(actual, decodeHex(test.cipherText)) {
+				t.Fatalf("FAILED: core %d block encryption failed", core)
+			}
+
+			actual, err = a.DecryptBlock(actual)
+			if err != nil {
+				panic(err)
+			}
+
+			if !bytes.Equal(actual, decodeHex(test.plainText)) {
+				t.Fatalf("FAILED: core %d block decryption failed", core)
+			}
+		}
+
+		for i, testKey := range testKeys {
+			a, err := NewAES256FromKey(testKey, WithCore(core))
+			if err != nil {
+				panic(err)
+			}
+
+			actual, err := a.EncryptBlock(zeroState)
+			if err != nil {
+				panic(err)
+			}
+
+			if !bytes.Equal(actual, encryptedStates[i]) {
+				t.Fatalf("FAILED: core %d block encryption failed", core)
+			}
+
+			actual, err = a.DecryptBlock(encryptedStates[i])
+			if err != nil {
+				panic(err)
+			}
+
+			if !bytes.Equal(actual, zeroState) {
+				t.Fatalf("FAILED: core %d block decryption failed", core)
+			}
+		}
+	}
+}
+
+func TestCoresMultiBlock(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	nonce := make([]byte, consts.NONCE_SIZE)
+	rng.Read(nonce)
+
+	for _, size := range []int{consts.KEY_SIZE_128, consts.KEY_SIZE_192, consts.KEY_SIZE_256} {
+		testKey := make([]byte, size)
+		rng.Read(testKey)
+
+		ref, err := NewAES256FromKey(testKey, WithCore(CoreTable))
+		if err != nil {
+			panic(err)
+		}
+
+		for _, core := range availableCores() {
+			a, err := NewAES256FromKey(testKey, WithCore(core))
+			if err != nil {
+				panic(err)
+			}
+
+			// Lengths around the batch sizes of every core.
+			for _, length := range []int{0, 1, 16, 63, 64, 65, 127, 128, 129, 300} {
+				data := make([]byte, length)
+				rng.Read(data)
+
+				expected, err := ref.coreBlockCTR(data, newTestCounter(nonce))
+				if err != nil {
+					panic(err)
+				}
+
+				actual, err := a.coreBlockCTR(data, newTestCounter(nonce))
+				if err != nil {
+					panic(err)
+				}
+
+				if !bytes.Equal(actual, expected) {
+					t.Fatalf("FAILED: core %d CTR mismatch for %d bytes", core, length)
+				}
+
+				blocks := data[:length/consts.BLOCK_SIZE*consts.BLOCK_SIZE]
+				expected = make([]byte, len(blocks))
+				actual = make([]byte, len(blocks))
+
+				ref.decryptBlocks(expected, blocks)
+				a.decryptBlocks(actual, blocks)
+
+				if !bytes.Equal(actual, expected) {
+					t.Fatalf("FAILED: core %d multi-block decryption mismatch for %d bytes", core, length)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkEncryptBlockAESNI(b *testing.B) {
+	if !supportsAESNI {
+		b.Skip("AES-NI not supported")
+	}
+
+	a, err := NewAES256FromKey(make([]byte, consts.KEY_SIZE), WithCore(CoreAESNI))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	block := make([]byte, consts.BLOCK_SIZE)
+	b.SetBytes(consts.BLOCK_SIZE)
+
+	for i := 0; i < b.N; i++ {
+		a.Encrypt(block, block)
+	}
+}
+
+func BenchmarkEncryptCTR(b *testing.B) {
+	for _, core := range availableCores() {
+		a, err := NewAES256FromKey(make([]byte, consts.KEY_SIZE), WithCore(core))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		data := make([]byte, 8192)
+		nonce := make([]byte, consts.NONCE_SIZE)
+
+		b.Run(map[Core]string{CoreTable: "Table", CoreBitsliced: "Bitsliced", CoreAESNI: "AESNI"}[core], func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+
+			for i := 0; i < b.N; i++ {
+				a.coreBlockCTR(data, newTestCounter(nonce))
+			}
+		})
+	}
+}
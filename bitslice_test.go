@@ -0,0 +1,211 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/wedkarz02/aes256go/src/bitslice"
+	"github.com/wedkarz02/aes256go/src/consts"
+	"github.com/wedkarz02/aes256go/src/padding"
+	"github.com/wedkarz02/aes256go/src/sbox"
+)
+
+func TestBitslicedSBox(t *testing.T) {
+	sb := sbox.InitSBOX()
+	invsb := sbox.InitInvSBOX(sb)
+
+	// Four blocks hold 64 bytes, so all of the 256
+	// inputs are covered in four passes.
+	input := make([]byte, bitslice.LANES*consts.BLOCK_SIZE)
+	output := make([]byte, len(input))
+
+	for base := 0; base < 256; base += len(input) {
+		for i := range input {
+			input[i] = byte(base + i)
+		}
+
+		var st bitslice.State
+		bitslice.Load(&st, input)
+		bitslice.SubBytes(&st)
+		bitslice.Store(&st, output)
+
+		for i, x := range input {
+			if output[i] != sb[x] {
+				t.Fatalf("FAILED: bitsliced S-box mismatch for 0x%02x", x)
+			}
+		}
+
+		bitslice.Load(&st, input)
+		bitslice.InvSubBytes(&st)
+		bitslice.Store(&st, output)
+
+		for i, x := range input {
+			if output[i] != invsb[x] {
+				t.Fatalf("FAILED: bitsliced inverse S-box mismatch for 0x%02x", x)
+			}
+		}
+	}
+}
+
+func TestBitslicedCoreMatchesTable(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+
+	for _, size := range []int{consts.KEY_SIZE_128, consts.KEY_SIZE_192, consts.KEY_SIZE_256} {
+		testKey := make([]byte, size)
+		rng.Read(testKey)
+
+		table, err := NewAES256FromKey(testKey, WithCore(CoreTable))
+		if err != nil {
+			panic(err)
+		}
+
+		bitsliced, err := NewAES256FromKey(testKey, WithCore(CoreBitsliced))
+		if err != nil {
+			panic(err)
+		}
+
+		// Odd block counts make sure partially filled
+		// lanes are handled as well.
+		for _, blocks := range []int{1, 2, 3, 4, 5, 9} {
+			data := make([]byte, blocks*consts.BLOCK_SIZE)
+			rng.Read(data)
+
+			expected := make([]byte, len(data))
+			actual := make([]byte, len(data))
+
+			table.encryptBlocks(expected, data)
+			bitsliced.encryptBlocks(actual, data)
+
+			if !bytes.Equal(actual, expected) {
+				t.Fatalf("FAILED: bitsliced encryption mismatch for %d byte key and %d blocks", size, blocks)
+			}
+
+			table.decryptBlocks(expected, data)
+			bitsliced.decryptBlocks(actual, data)
+
+			if !bytes.Equal(actual, expected) {
+				t.Fatalf("FAILED: bitsliced decryption mismatch for %d byte key and %d blocks", size, blocks)
+			}
+		}
+	}
+}
+
+func TestBitslicedCoreVectors(t *testing.T) {
+	for _, test := range fips197Tests {
+		if test.plainText == "" {
+			continue
+		}
+
+		a, err := NewAES256FromKey(decodeHex(test.key), WithCore(CoreBitsliced))
+		if err != nil {
+			panic(err)
+		}
+
+		actual, err := a.EncryptBlock(decodeHex(test.plainText))
+		if err != nil {
+			panic(err)
+		}
+
+		if !bytes.Equal(actual, decodeHex(test.cipherText)) {
+			t.Fatalf("FAILED: bitsliced block encryption failed")
+		}
+
+		actual, err = a.DecryptBlock(actual)
+		if err != nil {
+			panic(err)
+		}
+
+		if !bytes.Equal(actual, decodeHex(test.plainText)) {
+			t.Fatalf("FAILED: bitsliced block decryption failed")
+		}
+	}
+}
+
+func TestBitslicedCoreModes(t *testing.T) {
+	a, err := NewAES256([]byte("bitsliced modes key"), WithCore(CoreBitsliced))
+	if err != nil {
+		panic(err)
+	}
+
+	b, err := NewAES256([]byte("bitsliced modes key"))
+	if err != nil {
+		panic(err)
+	}
+
+	plainText := []byte("Constant time cores have to give the same results as the table ones.")
+
+	cipherText, err := a.EncryptECB(plainText, padding.PKCS7Padding)
+	if err != nil {
+		panic(err)
+	}
+
+	decrypted, err := b.DecryptECB(cipherText, padding.PKCS7Unpadding)
+	if err != nil || !bytes.Equal(decrypted, plainText) {
+		t.Fatalf("FAILED: bitsliced ECB round trip failed")
+	}
+
+	cipherText, err = b.EncryptGCM(plainText, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	decrypted, err = a.DecryptGCM(cipherText, nil)
+	if err != nil || !bytes.Equal(decrypted, plainText) {
+		t.Fatalf("FAILED: bitsliced GCM round trip failed")
+	}
+}
+
+func TestWithCoreInvalid(t *testing.T) {
+	if _, err := NewAES256([]byte("key"), WithCore(Core(-1))); err == nil {
+		t.Fatalf("FAILED: invalid core accepted")
+	}
+}
+
+func BenchmarkEncryptBlockBitsliced(b *testing.B) {
+	a, err := NewAES256FromKey(make([]byte, consts.KEY_SIZE), WithCore(CoreBitsliced))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	block := make([]byte, consts.BLOCK_SIZE)
+	b.SetBytes(consts.BLOCK_SIZE)
+
+	for i := 0; i < b.N; i++ {
+		a.Encrypt(block, block)
+	}
+}
+
+func BenchmarkEncryptECBBitsliced(b *testing.B) {
+	a, err := NewAES256FromKey(make([]byte, consts.KEY_SIZE), WithCore(CoreBitsliced))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	data := make([]byte, 1024)
+	b.SetBytes(int64(len(data)))
+
+	for i := 0; i < b.N; i++ {
+		a.encryptBlocks(data, data)
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"errors"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+)
+
+// CBCStealing selects the ciphertext stealing variant used by
+// EncryptCBCCS and DecryptCBCCS. The variants only differ in the
+// order of the last two cipherText blocks.
+//
+// https://csrc.nist.gov/publications/detail/sp/800-38a/addendum/final
+type CBCStealing int
+
+const (
+	// CBCCS1 keeps the partial block before the last full block.
+	CBCCS1 CBCStealing = iota + 1
+
+	// CBCCS2 swaps the last two blocks only if the last one
+	// is partial, so full block messages match plain CBC.
+	CBCCS2
+
+	// CBCCS3 always swaps the last two blocks,
+	// as used by Kerberos (RFC 3962).
+	CBCCS3
+)
+
+// Data encryption using CBC mode with ciphertext stealing.
+// The IV is prepended to the cipherText.
+//
+// No padding is used, so the cipherText is as long as the plainText,
+// which has to be at least one block long.
+//
+// https://csrc.nist.gov/publications/detail/sp/800-38a/addendum/final
+func (a *AES256) EncryptCBCCS(plainText []byte, variant CBCStealing) ([]byte, error) {
+	iv := make([]byte, consts.IV_SIZE)
+	if err := readRandom(a.rand, iv, "iv"); err != nil {
+		return nil, err
+	}
+
+	return a.EncryptCBCCSWithIV(iv, plainText, variant)
+}
+
+// Data encryption using CBC mode with ciphertext stealing and
+// the given IV, which is prepended to the cipherText.
+//
+// Stealing only changes how the last two blocks are handled, so the IV
+// has the same requirement as in plain CBC: it has to be unpredictable,
+// not just unique. With a fixed IV, like the zero IV used by Kerberos,
+// a random confounder has to start the plainText instead.
+//
+// https://csrc.nist.gov/publications/detail/sp/800-38a/addendum/final
+func (a *AES256) EncryptCBCCSWithIV(iv []byte, plainText []byte, variant CBCStealing) ([]byte, error) {
+	if variant < CBCCS1 || variant > CBCCS3 {
+		return nil, errors.New("invalid ciphertext stealing variant")
+	}
+
+	if len(iv) != consts.IV_SIZE {
+		return nil, errors.New("invalid iv size")
+	}
+
+	if len(plainText) < consts.BLOCK_SIZE {
+		return nil, errors.New("plainText too short for ciphertext stealing")
+	}
+
+	// The last partial block is padded with zeros and the whole
+	// message is chained as in regular CBC mode. The padding is
+	// then dropped from the second to last cipherText block.
+	n := (len(plainText) + consts.BLOCK_SIZE - 1) / consts.BLOCK_SIZE * consts.BLOCK_SIZE
+	d := len(plainText) - (n - consts.BLOCK_SIZE)
+
+	paddedPlain := make([]byte, n)
+	copy(paddedPlain, plainText)
+
+	chained := make([]byte, n)
+	a.cbcEncrypt(chained, paddedPlain, iv)
+
+	cipherText := make([]byte, consts.IV_SIZE, consts.IV_SIZE+len(plainText))
+	copy(cipherText, iv)
+
+	if n == consts.BLOCK_SIZE {
+		return append(cipherText, chained...), nil
+	}
+
+	head := chained[:n-2*consts.BLOCK_SIZE]
+	partial := chained[n-2*consts.BLOCK_SIZE : n-2*consts.BLOCK_SIZE+d]
+	last := chained[n-consts.BLOCK_SIZE:]
+
+	cipherText = append(cipherText, head...)
+
+	if variant == CBCCS1 || (variant == CBCCS2 && d == consts.BLOCK_SIZE) {
+		cipherText = append(cipherText, partial...)
+		return append(cipherText, last...), nil
+	}
+
+	cipherText = append(cipherText, last...)
+	return append(cipherText, partial...), nil
+}
+
+// Data decryption using CBC mode with ciphertext stealing.
+// The variant has to be the same as the one used for encryption.
+//
+// https://csrc.nist.gov/publications/detail/sp/800-38a/addendum/final
+func (a *AES256) DecryptCBCCS(cipherText []byte, variant CBCStealing) ([]byte, error) {
+	if variant < CBCCS1 || variant > CBCCS3 {
+		return nil, errors.New("invalid ciphertext stealing variant")
+	}
+
+	if len(cipherText) < consts.IV_SIZE+consts.BLOCK_SIZE {
+		return nil, errors.New("cipherText too short for ciphertext stealing")
+	}
+
+	iv := cipherText[:consts.IV_SIZE]
+	data := cipherText[consts.IV_SIZE:]
+
+	n := (len(data) + consts.BLOCK_SIZE - 1) / consts.BLOCK_SIZE * consts.BLOCK_SIZE
+	d := len(data) - (n - consts.BLOCK_SIZE)
+
+	plainText := make([]byte, n)
+
+	if n == consts.BLOCK_SIZE {
+		a.cbcDecrypt(plainText, data, iv)
+		return plainText, nil
+	}
+
+	// Bring the last two blocks back to the CS1 order.
+	chained := make([]byte, n)
+	copy(chained, data[:n-2*consts.BLOCK_SIZE])
+
+	partial := data[n-2*consts.BLOCK_SIZE : n-2*consts.BLOCK_SIZE+d]
+	last := data[n-2*consts.BLOCK_SIZE+d:]
+
+	if variant == CBCCS3 || (variant == CBCCS2 && d != consts.BLOCK_SIZE) {
+		last = data[n-2*consts.BLOCK_SIZE : n-consts.BLOCK_SIZE]
+		partial = data[n-consts.BLOCK_SIZE:]
+	}
+
+	// Decrypting the last block gives the second to last cipherText
+	// block masked with the zero padded plainText, so its stolen
+	// tail is exactly the part that was dropped during encryption.
+	masked := make([]byte, consts.BLOCK_SIZE)
+	a.decryptBlocks(masked, last)
+
+	copy(chained[n-2*consts.BLOCK_SIZE:], partial)
+	copy(chained[n-2*consts.BLOCK_SIZE+d:], masked[d:])
+	copy(chained[n-consts.BLOCK_SIZE:], last)
+
+	a.cbcDecrypt(plainText, chained, iv)
+
+	return plainText[:len(data)], nil
+}
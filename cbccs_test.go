@@ -0,0 +1,237 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+)
+
+// Test vectors from RFC 3962 Appendix B, which uses CBC-CS3
+// with an all zero IV and a 128 bit key.
+const cbcCSKey = "636869636b656e207465726979616b69"
+
+var cbcCSTests = []struct {
+	plainText  string
+	cipherText string
+}{
+	{
+		plainText:  "I would like the ",
+		cipherText: "c6353568f2bf8cb4d8a580362da7ff7f 97",
+	},
+	{
+		plainText:  "I would like the General Gau's ",
+		cipherText: "fc00783e0efdb2c1d445d4c8eff7ed22 97687268d6ecccc0c07b25e25ecfe5",
+	},
+	{
+		plainText:  "I would like the General Gau's C",
+		cipherText: "39312523a78662d5be7fcbcc98ebf5a8 97687268d6ecccc0c07b25e25ecfe584",
+	},
+	{
+		plainText: "I would like the General Gau's Chicken, please,",
+		cipherText: "97687268d6ecccc0c07b25e25ecfe584 b3fffd940c16a18c1b5549d2f838029e" +
+			"39312523a78662d5be7fcbcc98ebf5",
+	},
+	{
+		plainText: "I would like the General Gau's Chicken, please, ",
+		cipherText: "97687268d6ecccc0c07b25e25ecfe584 9dad8bbb96c4cdc03bc103e1a194bbd8" +
+			"39312523a78662d5be7fcbcc98ebf5a8",
+	},
+	{
+		plainText: "I would like the General Gau's Chicken, please, and wonton soup.",
+		cipherText: "97687268d6ecccc0c07b25e25ecfe584 39312523a78662d5be7fcbcc98ebf5a8" +
+			"4807efe836ee89a526730dbc2f7bc840 9dad8bbb96c4cdc03bc103e1a194bbd8",
+	},
+}
+
+// Test vectors from RFC 8009 Appendix A (aes256-cts-hmac-sha384-192),
+// which also uses CBC-CS3 with an all zero IV. The plainTexts are the
+// confounder followed by the message, the cipherTexts are without the
+// HMAC.
+const cbcCSKeyAES256 = "56ab22bee63d82d7bc5227f6773f8ea7 a5eb1c825160c38312980c442e5c7e49"
+
+var cbcCSTestsAES256 = []struct {
+	plainText  string
+	cipherText string
+}{
+	{
+		plainText:  "f764e9fa15c276478b2c7d0c4e5f58e4",
+		cipherText: "41f53fa5bfe7026d91faf9be959195a0",
+	},
+	{
+		plainText:  "b80d3251c1f6471494256ffe712d0b9a 000102030405",
+		cipherText: "4ed7b37c2bcac8f74f23c1cf07e62bc7 b75fb3f637b9",
+	},
+	{
+		plainText:  "53bf8a0d105265d4e276428624ce5e63 000102030405060708090a0b0c0d0e0f",
+		cipherText: "bc47ffec7998eb91e8115cf8d19dac4b bbe2e163e87dd37f49beca92027764f6",
+	},
+	{
+		plainText: "763e65367e864f02f55153c7e3b58af1 000102030405060708090a0b0c0d0e0f" +
+			"1011121314",
+		cipherText: "40013e2df58e8751957d2878bcd2d6fe 101ccfd556cb1eae79db3c3ee86429f2" +
+			"b2a602ac86",
+	},
+}
+
+// CBCCSFromCS3 reorders the last two blocks of a CS3 cipherText into
+// the given variant. The variants only differ in that order, which
+// lets the published CS3 vectors check CS1 and CS2 as well.
+func cbcCSFromCS3(cipherText []byte, variant CBCStealing) []byte {
+	if len(cipherText) <= consts.BLOCK_SIZE || variant == CBCCS3 {
+		return cipherText
+	}
+
+	d := len(cipherText) % consts.BLOCK_SIZE
+	if d == 0 {
+		d = consts.BLOCK_SIZE
+	}
+
+	// CS2 only swaps the blocks when the last one is partial.
+	if variant == CBCCS2 && d != consts.BLOCK_SIZE {
+		return cipherText
+	}
+
+	n := len(cipherText)
+	result := append([]byte{}, cipherText[:n-consts.BLOCK_SIZE-d]...)
+	result = append(result, cipherText[n-d:]...)
+
+	return append(result, cipherText[n-consts.BLOCK_SIZE-d:n-d]...)
+}
+
+func TestCBCCSVectors(t *testing.T) {
+	type vector struct {
+		plainText  []byte
+		cipherText []byte
+	}
+
+	vectors := map[string][]vector{}
+
+	for _, test := range cbcCSTests {
+		vectors[cbcCSKey] = append(vectors[cbcCSKey], vector{[]byte(test.plainText), decodeHex(test.cipherText)})
+	}
+
+	for _, test := range cbcCSTestsAES256 {
+		vectors[cbcCSKeyAES256] = append(vectors[cbcCSKeyAES256], vector{decodeHex(test.plainText), decodeHex(test.cipherText)})
+	}
+
+	iv := make([]byte, consts.IV_SIZE)
+
+	for k, tests := range vectors {
+		key := decodeHex(k)
+
+		a, err := NewAES256FromKey(key)
+		if err != nil {
+			panic(err)
+		}
+
+		for _, test := range tests {
+			for _, variant := range []CBCStealing{CBCCS1, CBCCS2, CBCCS3} {
+				cipherText, err := a.EncryptCBCCSWithIV(iv, test.plainText, variant)
+				if err != nil {
+					panic(err)
+				}
+
+				if !bytes.Equal(cipherText[consts.IV_SIZE:], cbcCSFromCS3(test.cipherText, variant)) {
+					t.Fatalf("FAILED: CS%d encryption failed for %d bytes with a %d bit key", variant, len(test.plainText), 8*len(key))
+				}
+
+				actual, err := a.DecryptCBCCS(cipherText, variant)
+				if err != nil {
+					panic(err)
+				}
+
+				if !bytes.Equal(actual, test.plainText) {
+					t.Fatalf("FAILED: CS%d decryption failed for %d bytes with a %d bit key", variant, len(test.plainText), 8*len(key))
+				}
+
+				// CS2 matches plain CBC for messages made of whole blocks.
+				if variant == CBCCS2 && len(test.plainText)%consts.BLOCK_SIZE == 0 {
+					cbc, err := a.EncryptCBCWithIV(iv, test.plainText, func(b []byte) []byte { return b })
+					if err != nil {
+						panic(err)
+					}
+
+					if !bytes.Equal(cipherText, cbc) {
+						t.Fatalf("FAILED: CS2 does not match CBC for %d bytes", len(test.plainText))
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestCBCCS(t *testing.T) {
+	a, err := NewAES256([]byte("cbc-cs key"))
+	if err != nil {
+		panic(err)
+	}
+
+	plainText := bytes.Repeat([]byte("ciphertext stealing "), 4)
+
+	for _, variant := range []CBCStealing{CBCCS1, CBCCS2, CBCCS3} {
+		// Lengths at and around the block boundaries.
+		for _, length := range []int{16, 17, 31, 32, 33, 47, 48, 49, 64, 65} {
+			cipherText, err := a.EncryptCBCCS(plainText[:length], variant)
+			if err != nil {
+				panic(err)
+			}
+
+			if len(cipherText) != consts.IV_SIZE+length {
+				t.Fatalf("FAILED: CS%d cipherText length changed for %d bytes", variant, length)
+			}
+
+			actual, err := a.DecryptCBCCS(cipherText, variant)
+			if err != nil || !bytes.Equal(actual, plainText[:length]) {
+				t.Fatalf("FAILED: CS%d round trip failed for %d bytes", variant, length)
+			}
+		}
+	}
+}
+
+func TestCBCCSErrors(t *testing.T) {
+	a, err := NewAES256([]byte("cbc-cs key"))
+	if err != nil {
+		panic(err)
+	}
+
+	if _, err := a.EncryptCBCCS(make([]byte, consts.BLOCK_SIZE-1), CBCCS1); err == nil {
+		t.Fatalf("FAILED: short plainText accepted")
+	}
+
+	if _, err := a.DecryptCBCCS(make([]byte, consts.IV_SIZE+consts.BLOCK_SIZE-1), CBCCS1); err == nil {
+		t.Fatalf("FAILED: short cipherText accepted")
+	}
+
+	if _, err := a.EncryptCBCCS(make([]byte, consts.BLOCK_SIZE), CBCStealing(0)); err == nil {
+		t.Fatalf("FAILED: invalid variant accepted")
+	}
+
+	if _, err := a.DecryptCBCCS(make([]byte, consts.IV_SIZE+consts.BLOCK_SIZE), CBCStealing(4)); err == nil {
+		t.Fatalf("FAILED: invalid variant accepted")
+	}
+
+	if _, err := a.EncryptCBCCSWithIV(make([]byte, consts.IV_SIZE-1), make([]byte, consts.BLOCK_SIZE), CBCCS1); err == nil {
+		t.Fatalf("FAILED: invalid iv accepted")
+	}
+}
@@ -0,0 +1,303 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+	"github.com/wedkarz02/aes256go/src/counter"
+)
+
+// Ccm implements the cipher.AEAD interface in Counter
+// with CBC-MAC mode.
+type ccm struct {
+	cipher    *AES256
+	nonceSize int
+	tagSize   int
+}
+
+// NewCCM returns the cipher wrapped in Counter with CBC-MAC mode
+// as a cipher.AEAD with the given nonce and tag sizes.
+//
+// The nonce has to be 7 to 13 bytes long, the tag 4, 6, 8, 10, 12,
+// 14 or 16 bytes long. The shorter the nonce, the longer the messages
+// can be: 15 - nonceSize bytes encode the length of the plainText.
+//
+// https://nvlpubs.nist.gov/nistpubs/Legacy/SP/nistspecialpublication800-38c.pdf
+func (a *AES256) NewCCM(nonceSize, tagSize int) (cipher.AEAD, error) {
+	if nonceSize < 7 || nonceSize > 13 {
+		return nil, errors.New("invalid nonce size")
+	}
+
+	if tagSize < 4 || tagSize > consts.TAG_SIZE || tagSize%2 != 0 {
+		return nil, errors.New("invalid tag size")
+	}
+
+	return &ccm{cipher: a, nonceSize: nonceSize, tagSize: tagSize}, nil
+}
+
+// Data encryption and authentication using CCM mode with a random nonce
+// of nonceSize bytes. Nonce is prepended to the cipherText and the
+// authentication tag of tagSize bytes is appended to the cipherText.
+//
+// Both plainText and authData will be authenticated, but only plainText is encrypted.
+//
+// https://nvlpubs.nist.gov/nistpubs/Legacy/SP/nistspecialpublication800-38c.pdf
+func (a *AES256) EncryptCCM(plainText []byte, authData []byte, nonceSize, tagSize int) ([]byte, error) {
+	if nonceSize < 7 || nonceSize > 13 {
+		return nil, errors.New("invalid nonce size")
+	}
+
+	nonce := make([]byte, nonceSize)
+	if err := readRandom(a.rand, nonce, "nonce"); err != nil {
+		return nil, err
+	}
+
+	return a.EncryptCCMWithNonce(nonce, plainText, authData, tagSize)
+}
+
+// Data encryption and authentication using CCM mode with the given nonce.
+// Nonce is prepended to the cipherText and the authentication tag
+// of tagSize bytes is appended to the cipherText.
+//
+// The nonce must never be reused with the same key.
+//
+// https://nvlpubs.nist.gov/nistpubs/Legacy/SP/nistspecialpublication800-38c.pdf
+func (a *AES256) EncryptCCMWithNonce(nonce []byte, plainText []byte, authData []byte, tagSize int) ([]byte, error) {
+	aead, err := a.NewCCM(len(nonce), tagSize)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c := aead.(*ccm)
+	if !c.fits(len(plainText)) {
+		return nil, errors.New("plainText too long for the nonce size")
+	}
+
+	output := make([]byte, 0, len(nonce)+len(plainText)+tagSize)
+	output = append(output, nonce...)
+
+	return c.Seal(output, nonce, plainText, authData), nil
+}
+
+// Data decryption and authentication using CCM mode. Nonce of nonceSize
+// bytes is prepended to the cipherText and the authentication tag
+// of tagSize bytes is appended to the cipherText.
+//
+// Both cipherText and authData will be authenticated, but only cipherText is decrypted.
+//
+// https://nvlpubs.nist.gov/nistpubs/Legacy/SP/nistspecialpublication800-38c.pdf
+func (a *AES256) DecryptCCM(cipherText []byte, authData []byte, nonceSize, tagSize int) ([]byte, error) {
+	aead, err := a.NewCCM(nonceSize, tagSize)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cipherText) < nonceSize+tagSize {
+		return nil, errors.New("CCM authentication failed: cipherText too short")
+	}
+
+	return aead.Open(nil, cipherText[:nonceSize], cipherText[nonceSize:], authData)
+}
+
+func (c *ccm) NonceSize() int {
+	return c.nonceSize
+}
+
+func (c *ccm) Overhead() int {
+	return c.tagSize
+}
+
+// Seal encrypts and authenticates plaintext, authenticates
+// additionalData and appends the result to dst.
+//
+// To reuse plaintext's storage for the output, use plaintext[:0] as dst.
+func (c *ccm) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != c.nonceSize {
+		panic("aes256go: incorrect nonce length given to CCM")
+	}
+
+	if !c.fits(len(plaintext)) {
+		panic("aes256go: message too large for CCM")
+	}
+
+	ret, out := sliceForAppend(dst, len(plaintext)+c.tagSize)
+
+	if inexactOverlap(out, plaintext) {
+		panic("aes256go: invalid buffer overlap")
+	}
+
+	tag := c.tag(nonce, plaintext, additionalData)
+
+	cipherText, err := c.cipher.coreBlockCTR(plaintext, c.newCounter(nonce, 1))
+
+	if err != nil {
+		panic(err)
+	}
+
+	copy(out, cipherText)
+	copy(out[len(plaintext):], tag)
+
+	return ret
+}
+
+// Open decrypts and authenticates ciphertext, authenticates
+// additionalData and, if successful, appends the resulting
+// plaintext to dst.
+//
+// To reuse ciphertext's storage for the output, use ciphertext[:0] as dst.
+func (c *ccm) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != c.nonceSize {
+		panic("aes256go: incorrect nonce length given to CCM")
+	}
+
+	if len(ciphertext) < c.tagSize {
+		return nil, errors.New("CCM authentication failed: cipherText too short")
+	}
+
+	tag := ciphertext[len(ciphertext)-c.tagSize:]
+	ciphertext = ciphertext[:len(ciphertext)-c.tagSize]
+
+	if !c.fits(len(ciphertext)) {
+		return nil, errors.New("CCM authentication failed: cipherText too long")
+	}
+
+	ret, out := sliceForAppend(dst, len(ciphertext))
+
+	if inexactOverlap(out, ciphertext) {
+		panic("aes256go: invalid buffer overlap")
+	}
+
+	// CBC-MAC is calculated over the plainText, so it has to be
+	// decrypted first, but it is only released after the tag
+	// has been verified.
+	plainText, err := c.cipher.coreBlockCTR(ciphertext, c.newCounter(nonce, 1))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare(tag, c.tag(nonce, plainText, additionalData)) != 1 {
+		for i := range plainText {
+			plainText[i] = 0x00
+		}
+
+		return nil, errors.New("CCM authentication failed: Invalid authentication tag")
+	}
+
+	copy(out, plainText)
+
+	return ret, nil
+}
+
+// Fits reports whether a message of the given length can
+// be encoded in the 15 - nonceSize byte length field.
+func (c *ccm) fits(length int) bool {
+	q := consts.BLOCK_SIZE - 1 - c.nonceSize
+	return q >= 8 || uint64(length) < 1<<(8*uint(q))
+}
+
+// NewCounter returns the counter block Ctr_i:
+// flags || nonce || [i]q, with q bytes of counter.
+func (c *ccm) newCounter(nonce []byte, i int) *counter.Counter {
+	q := consts.BLOCK_SIZE - 1 - c.nonceSize
+
+	block := make([]byte, consts.BLOCK_SIZE)
+	block[0] = byte(q - 1)
+	copy(block[1:], nonce)
+
+	ctr, err := counter.NewCounterBlock(block, q)
+
+	if err != nil {
+		panic(err)
+	}
+
+	for ; i > 0; i-- {
+		ctr.Increment()
+	}
+
+	return ctr
+}
+
+// Tag calculates the CBC-MAC of the formatted input
+// B0 || encoded authData || plainText, encrypted with Ctr_0.
+//
+// https://nvlpubs.nist.gov/nistpubs/Legacy/SP/nistspecialpublication800-38c.pdf (Appendix A)
+func (c *ccm) tag(nonce, plainText, authData []byte) []byte {
+	q := consts.BLOCK_SIZE - 1 - c.nonceSize
+
+	b0 := make([]byte, consts.BLOCK_SIZE)
+	b0[0] = byte(8*((c.tagSize-2)/2) + (q - 1))
+	copy(b0[1:], nonce)
+
+	var length [8]byte
+	binary.BigEndian.PutUint64(length[:], uint64(len(plainText)))
+	copy(b0[consts.BLOCK_SIZE-q:], length[8-q:])
+
+	var encodedAuth []byte
+
+	if len(authData) > 0 {
+		b0[0] |= 0x40
+
+		switch n := uint64(len(authData)); {
+		case n < 1<<16-1<<8:
+			encodedAuth = binary.BigEndian.AppendUint16(nil, uint16(n))
+		case n < 1<<32:
+			encodedAuth = binary.BigEndian.AppendUint32([]byte{0xff, 0xfe}, uint32(n))
+		default:
+			encodedAuth = binary.BigEndian.AppendUint64([]byte{0xff, 0xff}, n)
+		}
+
+		encodedAuth = append(encodedAuth, authData...)
+	}
+
+	mac := make([]byte, consts.BLOCK_SIZE)
+	c.cipher.encryptBlocks(mac, b0)
+
+	c.cbcMAC(mac, encodedAuth)
+	c.cbcMAC(mac, plainText)
+
+	tag, err := c.cipher.coreBlockCTR(mac[:c.tagSize], c.newCounter(nonce, 0))
+
+	if err != nil {
+		panic(err)
+	}
+
+	return tag
+}
+
+// CbcMAC chains the data into mac, zero padding
+// the last block.
+func (c *ccm) cbcMAC(mac, data []byte) {
+	for i := 0; i < len(data); i += consts.BLOCK_SIZE {
+		for j := 0; j < consts.BLOCK_SIZE && i+j < len(data); j++ {
+			mac[j] ^= data[i+j]
+		}
+
+		c.cipher.encryptBlocks(mac, mac)
+	}
+}
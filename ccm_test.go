@@ -0,0 +1,270 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+)
+
+// Examples 1-4 from NIST SP 800-38C Appendix C and packet vectors
+// 1-2 from RFC 3610, which use 128 bit keys, followed by AES-256
+// vectors from the NIST CAVP CCM test files.
+var ccmTests = []struct {
+	name       string
+	key        string
+	nonce      string
+	authData   string
+	plainText  string
+	cipherText string
+	tagSize    int
+}{
+	{
+		name:       "SP 800-38C Example 1",
+		key:        "404142434445464748494a4b4c4d4e4f",
+		nonce:      "10111213141516",
+		authData:   "0001020304050607",
+		plainText:  "20212223",
+		cipherText: "7162015b 4dac255d",
+		tagSize:    4,
+	},
+	{
+		name:       "SP 800-38C Example 2",
+		key:        "404142434445464748494a4b4c4d4e4f",
+		nonce:      "1011121314151617",
+		authData:   "000102030405060708090a0b0c0d0e0f",
+		plainText:  "202122232425262728292a2b2c2d2e2f",
+		cipherText: "d2a1f0e051ea5f62081a7792073d593d 1fc64fbfaccd",
+		tagSize:    6,
+	},
+	{
+		name:       "SP 800-38C Example 3",
+		key:        "404142434445464748494a4b4c4d4e4f",
+		nonce:      "101112131415161718191a1b",
+		authData:   "000102030405060708090a0b0c0d0e0f10111213",
+		plainText:  "202122232425262728292a2b2c2d2e2f3031323334353637",
+		cipherText: "e3b201a9f5b71a7a9b1ceaeccd97e70b6176aad9a4428aa5 484392fbc1b09951",
+		tagSize:    8,
+	},
+	{
+		name:       "RFC 3610 Packet Vector #1",
+		key:        "c0c1c2c3c4c5c6c7c8c9cacbcccdcecf",
+		nonce:      "00000003020100a0a1a2a3a4a5",
+		authData:   "0001020304050607",
+		plainText:  "08090a0b0c0d0e0f101112131415161718191a1b1c1d1e",
+		cipherText: "588c979a61c663d2f066d0c2c0f989806d5f6b61dac384 17e8d12cfdf926e0",
+		tagSize:    8,
+	},
+	{
+		name:       "RFC 3610 Packet Vector #2",
+		key:        "c0c1c2c3c4c5c6c7c8c9cacbcccdcecf",
+		nonce:      "00000004030201a0a1a2a3a4a5",
+		authData:   "0001020304050607",
+		plainText:  "08090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+		cipherText: "72c91a36e135f8cf291ca894085c87e3cc15c439c9e43a3b a091d56e10400916",
+		tagSize:    8,
+	},
+	{
+		name:       "CAVP DVPT256 Nlen 7 Tlen 4",
+		key:        "eda32f751456e33195f1f499cf2dc7c97ea127b6d488f211ccc5126fbb24afa6",
+		nonce:      "a544218dadd3c1",
+		cipherText: "469c90bb",
+		tagSize:    4,
+	},
+	{
+		name:       "CAVP DVPT256 Nlen 7 Tlen 4, second nonce",
+		key:        "eda32f751456e33195f1f499cf2dc7c97ea127b6d488f211ccc5126fbb24afa6",
+		nonce:      "dbb3923156cfd6",
+		cipherText: "1302d515",
+		tagSize:    4,
+	},
+	{
+		name:       "CAVP DVPT256 Nlen 7 Tlen 16",
+		key:        "e1b8a927a95efe94656677b692662000278b441c79e879dd5c0ddc758bdc9ee8",
+		nonce:      "a544218dadd3c1",
+		cipherText: "8207eb14d33855a52acceed17dbcbf6e",
+		tagSize:    16,
+	},
+	{
+		name:      "CAVP VNT256 Nlen 7",
+		key:       "553521a765ab0c3fd203654e9916330e189bdf951feee9b44b10da208fee7acf",
+		nonce:     "aaa23f101647d8",
+		authData:  "a355d4c611812e5f9258d7188b3df8851477094ffc2af2cf0c8670db903fbbe0",
+		plainText: "644eb34b9a126e437b5e015eea141ca1a88020f2d5d6cc2c",
+		cipherText: "27ed90668174ebf8241a3c74b35e1246b6617e4123578f15" +
+			"3bdb67062a13ef4e986f5bb3d0bb4307",
+		tagSize: 16,
+	},
+	{
+		name:       "CAVP VPT256 Plen 0",
+		key:        "c6c14c655e52c8a4c7e8d54e974d698e1f21ee3ba717a0adfa6136d02668c476",
+		nonce:      "291e91b19de518cd7806de44f6",
+		authData:   "b4f8326944a45d95f91887c2a6ac36b60eea5edef84c1c358146a666b6878335",
+		cipherText: "ca482c674b599046cc7d7ee0d00eec1e",
+		tagSize:    16,
+	},
+}
+
+func TestCCMVectors(t *testing.T) {
+	for _, test := range ccmTests {
+		a, err := NewAES256FromKey(decodeHex(test.key))
+		if err != nil {
+			panic(err)
+		}
+
+		nonce := decodeHex(test.nonce)
+		expected := append(decodeHex(test.nonce), decodeHex(test.cipherText)...)
+
+		actual, err := a.EncryptCCMWithNonce(nonce, decodeHex(test.plainText), decodeHex(test.authData), test.tagSize)
+		if err != nil || !bytes.Equal(actual, expected) {
+			t.Fatalf("FAILED: %s encryption failed", test.name)
+		}
+
+		plainText, err := a.DecryptCCM(expected, decodeHex(test.authData), len(nonce), test.tagSize)
+		if err != nil || !bytes.Equal(plainText, decodeHex(test.plainText)) {
+			t.Fatalf("FAILED: %s decryption failed", test.name)
+		}
+
+		expected[len(nonce)] ^= 0x01
+
+		if _, err := a.DecryptCCM(expected, decodeHex(test.authData), len(nonce), test.tagSize); err == nil {
+			t.Fatalf("FAILED: %s accepted a modified cipherText", test.name)
+		}
+	}
+}
+
+// Example 4 of NIST SP 800-38C has 2^16 bytes of associated
+// data, which needs the 0xfffe length encoding.
+func TestCCMLongAuthData(t *testing.T) {
+	a, err := NewAES256FromKey(decodeHex("404142434445464748494a4b4c4d4e4f"))
+	if err != nil {
+		panic(err)
+	}
+
+	authData := make([]byte, 1<<16)
+	for i := range authData {
+		authData[i] = byte(i)
+	}
+
+	aead, err := a.NewCCM(13, 14)
+	if err != nil {
+		panic(err)
+	}
+
+	expected := decodeHex("69915dad1e84c6376a68c2967e4dab615ae0fd1faec44cc484828529463ccf72 b4ac6bec93e8598e7f0dadbcea5b")
+	actual := aead.Seal(nil, decodeHex("101112131415161718191a1b1c"), decodeHex("202122232425262728292a2b2c2d2e2f303132333435363738393a3b3c3d3e3f"), authData)
+
+	if !bytes.Equal(actual, expected) {
+		t.Fatalf("FAILED: SP 800-38C Example 4 encryption failed")
+	}
+}
+
+func TestCCMParameters(t *testing.T) {
+	a, err := NewAES256FromKey(bytes.Repeat([]byte{0x61}, consts.KEY_SIZE))
+	if err != nil {
+		panic(err)
+	}
+
+	plainText := bytes.Repeat([]byte("constrained devices "), 5)
+	authData := []byte("header")
+
+	for nonceSize := 7; nonceSize <= 13; nonceSize++ {
+		for tagSize := 4; tagSize <= consts.TAG_SIZE; tagSize += 2 {
+			for _, size := range []int{0, 1, 16, 17, len(plainText)} {
+				cipherText, err := a.EncryptCCM(plainText[:size], authData, nonceSize, tagSize)
+				if err != nil {
+					t.Fatalf("FAILED: CCM(%d, %d) encryption failed: %v", nonceSize, tagSize, err)
+				}
+
+				if len(cipherText) != nonceSize+size+tagSize {
+					t.Fatalf("FAILED: CCM(%d, %d) returned wrong length", nonceSize, tagSize)
+				}
+
+				decrypted, err := a.DecryptCCM(cipherText, authData, nonceSize, tagSize)
+				if err != nil || !bytes.Equal(decrypted, plainText[:size]) {
+					t.Fatalf("FAILED: CCM(%d, %d) round trip failed for %d bytes", nonceSize, tagSize, size)
+				}
+
+				if _, err := a.DecryptCCM(cipherText, authData[1:], nonceSize, tagSize); err == nil {
+					t.Fatalf("FAILED: CCM(%d, %d) accepted wrong additional data", nonceSize, tagSize)
+				}
+			}
+		}
+	}
+
+	for _, params := range [][2]int{{6, 8}, {14, 8}, {12, 2}, {12, 5}, {12, 18}} {
+		if _, err := a.NewCCM(params[0], params[1]); err == nil {
+			t.Fatalf("FAILED: CCM(%d, %d) accepted", params[0], params[1])
+		}
+	}
+
+	// A 13 byte nonce leaves 2 bytes for the message length.
+	if _, err := a.EncryptCCM(make([]byte, 1<<16), nil, 13, 16); err == nil {
+		t.Fatalf("FAILED: CCM accepted a message longer than the length field")
+	}
+
+	if _, err := a.EncryptCCM(make([]byte, 1<<16-1), nil, 13, 16); err != nil {
+		t.Fatalf("FAILED: CCM rejected the longest message: %v", err)
+	}
+}
+
+func TestCCMAEAD(t *testing.T) {
+	a, err := NewAES256FromKey(make([]byte, consts.KEY_SIZE))
+	if err != nil {
+		panic(err)
+	}
+
+	aead, err := a.NewCCM(12, 16)
+	if err != nil {
+		panic(err)
+	}
+
+	if aead.NonceSize() != 12 || aead.Overhead() != 16 {
+		t.Fatalf("FAILED: CCM parameters do not match")
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	plainText := []byte("encrypted and decrypted in place")
+
+	buf := make([]byte, len(plainText), len(plainText)+aead.Overhead())
+	copy(buf, plainText)
+
+	sealed := aead.Seal(buf[:0], nonce, buf, nil)
+
+	if &sealed[0] != &buf[0] {
+		t.Fatalf("FAILED: Seal did not reuse the plaintext storage")
+	}
+
+	opened, err := aead.Open(sealed[:0], nonce, sealed, nil)
+	if err != nil || !bytes.Equal(opened, plainText) {
+		t.Fatalf("FAILED: in place Open failed")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("FAILED: wrong nonce size did not panic")
+		}
+	}()
+
+	aead.Seal(nil, nonce[1:], plainText, nil)
+}
@@ -0,0 +1,187 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"errors"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+)
+
+// Data encryption using CFB mode with the segment size given in bits,
+// which allows the CFB-1 variant. The IV is prepended to the cipherText.
+//
+// 1 <= bits <= 128 (block size)
+//
+// The data is processed as a bit string, most significant bit first.
+// If its length is not a multiple of the segment size, the last segment
+// is truncated.
+//
+// https://nvlpubs.nist.gov/nistpubs/Legacy/SP/nistspecialpublication800-38a.pdf
+func (a *AES256) EncryptCFBBits(plainText []byte, bits int) ([]byte, error) {
+	iv := make([]byte, consts.IV_SIZE)
+	if err := readRandom(a.rand, iv, "iv"); err != nil {
+		return nil, err
+	}
+
+	return a.EncryptCFBBitsWithIV(iv, plainText, bits)
+}
+
+// Data encryption using CFB mode with the segment size given in bits
+// and the given IV, which is prepended to the cipherText.
+//
+// 1 <= bits <= 128 (block size)
+//
+// The IV has to be unpredictable like in EncryptCFBWithIV. With small
+// segments a repeated IV is worse than with full blocks: the keystream
+// stays identical until the plainTexts differ, so their whole common
+// prefix is revealed along with the XOR of the first differing segment.
+//
+// https://nvlpubs.nist.gov/nistpubs/Legacy/SP/nistspecialpublication800-38a.pdf
+func (a *AES256) EncryptCFBBitsWithIV(iv []byte, plainText []byte, bits int) ([]byte, error) {
+	if bits < 1 || bits > 8*consts.BLOCK_SIZE {
+		return nil, errors.New("invalid segment size")
+	}
+
+	if len(iv) != consts.IV_SIZE {
+		return nil, errors.New("invalid iv size")
+	}
+
+	cipherText := make([]byte, consts.IV_SIZE+len(plainText))
+	copy(cipherText, iv)
+
+	a.cfb(cipherText[consts.IV_SIZE:], plainText, iv, bits, false)
+
+	return cipherText, nil
+}
+
+// Data decryption using CFB mode with the segment size given in bits.
+//
+// 1 <= bits <= 128 (block size)
+//
+// https://nvlpubs.nist.gov/nistpubs/Legacy/SP/nistspecialpublication800-38a.pdf
+func (a *AES256) DecryptCFBBits(cipherText []byte, bits int) ([]byte, error) {
+	if bits < 1 || bits > 8*consts.BLOCK_SIZE {
+		return nil, errors.New("invalid segment size")
+	}
+
+	if len(cipherText) < consts.IV_SIZE {
+		return nil, errors.New("cipherText too short")
+	}
+
+	plainText := make([]byte, len(cipherText)-consts.IV_SIZE)
+	a.cfb(plainText, cipherText[consts.IV_SIZE:], cipherText[:consts.IV_SIZE], bits, true)
+
+	return plainText, nil
+}
+
+// Cfb processes src into dst in CFB mode with s bit segments. The input
+// register starts as the IV and every cipherText segment is shifted
+// into it from the right.
+func (a *AES256) cfb(dst, src, iv []byte, s int, decrypt bool) {
+	reg := make([]byte, consts.BLOCK_SIZE)
+	copy(reg, iv)
+
+	out := make([]byte, consts.BLOCK_SIZE)
+	in := make([]byte, consts.BLOCK_SIZE)
+	seg := make([]byte, consts.BLOCK_SIZE)
+
+	total := 8 * len(src)
+
+	for off := 0; off < total; off += s {
+		n := s
+		if total-off < n {
+			n = total - off
+		}
+
+		a.encryptBlocks(out, reg)
+		readBits(in, src, off, n)
+
+		for i := range seg {
+			seg[i] = in[i] ^ out[i]
+		}
+
+		writeBits(dst, seg, off, n)
+
+		// The feedback is always the cipherText segment.
+		if decrypt {
+			shiftIn(reg, in, s)
+		} else {
+			shiftIn(reg, seg, s)
+		}
+	}
+}
+
+// ReadBits copies n bits of src starting at bit offset off
+// to the beginning of dst. The remaining bits of dst are zeroed.
+func readBits(dst, src []byte, off, n int) {
+	for i := range dst {
+		dst[i] = 0x00
+	}
+
+	if off%8 == 0 && n%8 == 0 {
+		copy(dst, src[off/8:off/8+n/8])
+		return
+	}
+
+	for i := 0; i < n; i++ {
+		bit := src[(off+i)/8] >> (7 - (off+i)%8) & 0x01
+		dst[i/8] |= bit << (7 - i%8)
+	}
+}
+
+// WriteBits copies the first n bits of src to dst
+// at bit offset off, leaving other bits of dst intact.
+func writeBits(dst, src []byte, off, n int) {
+	if off%8 == 0 && n%8 == 0 {
+		copy(dst[off/8:off/8+n/8], src)
+		return
+	}
+
+	for i := 0; i < n; i++ {
+		bit := src[i/8] >> (7 - i%8) & 0x01
+		pos := off + i
+
+		dst[pos/8] = dst[pos/8]&^(0x01<<(7-pos%8)) | bit<<(7-pos%8)
+	}
+}
+
+// ShiftIn shifts the register left by s bits and
+// fills the freed bits with the first s bits of in.
+func shiftIn(reg, in []byte, s int) {
+	byteShift, bitShift := s/8, s%8
+
+	for i := range reg {
+		var b byte
+
+		if j := i + byteShift; j < len(reg) {
+			b = reg[j] << bitShift
+
+			if bitShift != 0 && j+1 < len(reg) {
+				b |= reg[j+1] >> (8 - bitShift)
+			}
+		}
+
+		reg[i] = b
+	}
+
+	writeBits(reg, in, 8*len(reg)-s, s)
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+)
+
+// AES-256 CFB example vectors from NIST SP 800-38A Appendix F.3.
+// CFB1 processes the first 16 bits of the plainText,
+// CFB8 the first 18 bytes.
+var cfbBitsTests = []struct {
+	mode       string
+	bits       int
+	plainText  string
+	cipherText string
+}{
+	{
+		mode:       "CFB1",
+		bits:       1,
+		plainText:  "6bc1",
+		cipherText: "9029",
+	},
+	{
+		mode:       "CFB8",
+		bits:       8,
+		plainText:  "6bc1bee22e409f96e93d7e117393172aae2d",
+		cipherText: "dc1f1a8520a64db55fcc8ac554844e889700",
+	},
+	{
+		mode:      "CFB128",
+		bits:      128,
+		plainText: sp80038aPlainText,
+		cipherText: "dc7e84bfda79164b7ecd8486985d3860 39ffed143b28b1c832113c6331e5407b" +
+			"df10132415e54b92a13ed0a8267ae2f9 75a385741ab9cef82031623d55b1e471",
+	},
+}
+
+func TestCFBBitsVectors(t *testing.T) {
+	a, err := NewAES256FromKey(decodeHex(sp80038aKey))
+	if err != nil {
+		panic(err)
+	}
+
+	iv := decodeHex(sp80038aIV)
+
+	for _, test := range cfbBitsTests {
+		plainText := decodeHex(test.plainText)
+		expected := append(decodeHex(sp80038aIV), decodeHex(test.cipherText)...)
+
+		actual, err := a.EncryptCFBBitsWithIV(iv, plainText, test.bits)
+		if err != nil {
+			panic(err)
+		}
+
+		if !bytes.Equal(actual, expected) {
+			t.Fatalf("FAILED: %s-AES256 encryption failed", test.mode)
+		}
+
+		actual, err = a.DecryptCFBBits(expected, test.bits)
+		if err != nil {
+			panic(err)
+		}
+
+		if !bytes.Equal(actual, plainText) {
+			t.Fatalf("FAILED: %s-AES256 decryption failed", test.mode)
+		}
+
+		if test.bits%8 != 0 {
+			continue
+		}
+
+		// The byte based variant has to give the same results.
+		actual, err = a.EncryptCFBWithIV(iv, plainText, test.bits/8)
+		if err != nil {
+			panic(err)
+		}
+
+		if !bytes.Equal(actual, expected) {
+			t.Fatalf("FAILED: %s-AES256 byte segment encryption failed", test.mode)
+		}
+
+		actual, err = a.DecryptCFB(expected, test.bits/8)
+		if err != nil {
+			panic(err)
+		}
+
+		if !bytes.Equal(actual, plainText) {
+			t.Fatalf("FAILED: %s-AES256 byte segment decryption failed", test.mode)
+		}
+	}
+}
+
+func TestCFBBits(t *testing.T) {
+	rng := rand.New(rand.NewSource(23))
+
+	a, err := NewAES256([]byte("cfb key"))
+	if err != nil {
+		panic(err)
+	}
+
+	iv := make([]byte, consts.IV_SIZE)
+	rng.Read(iv)
+
+	for bits := 1; bits <= 8*consts.BLOCK_SIZE; bits++ {
+		plainText := make([]byte, 1+rng.Intn(3*consts.BLOCK_SIZE))
+		rng.Read(plainText)
+
+		cipherText, err := a.EncryptCFBBitsWithIV(iv, plainText, bits)
+		if err != nil {
+			panic(err)
+		}
+
+		actual, err := a.DecryptCFBBits(cipherText, bits)
+		if err != nil {
+			panic(err)
+		}
+
+		if !bytes.Equal(actual, plainText) {
+			t.Fatalf("FAILED: CFB round trip failed for %d bit segments", bits)
+		}
+
+		// A segment only depends on the previous ones, so encrypting
+		// a prefix gives a prefix of the cipherText.
+		prefix, err := a.EncryptCFBBitsWithIV(iv, plainText[:len(plainText)/2], bits)
+		if err != nil {
+			panic(err)
+		}
+
+		if !bytes.Equal(prefix, cipherText[:len(prefix)]) {
+			t.Fatalf("FAILED: CFB prefix mismatch for %d bit segments", bits)
+		}
+	}
+
+	for _, bits := range []int{0, 8*consts.BLOCK_SIZE + 1} {
+		if _, err := a.EncryptCFBBits(nil, bits); err == nil {
+			t.Fatalf("FAILED: %d bit segments accepted", bits)
+		}
+
+		if _, err := a.DecryptCFBBits(make([]byte, consts.IV_SIZE), bits); err == nil {
+			t.Fatalf("FAILED: %d bit segments accepted", bits)
+		}
+	}
+
+	if _, err := a.DecryptCFBBits(make([]byte, consts.IV_SIZE-1), 1); err == nil {
+		t.Fatalf("FAILED: short cipherText accepted")
+	}
+}
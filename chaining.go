@@ -0,0 +1,150 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"errors"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+	"github.com/wedkarz02/aes256go/src/padding"
+)
+
+// ChainMode is a block cipher mode of operation which processes whole
+// blocks, each of them chained with the previous ones in its own way.
+// Padding and the IV header are handled by encryptChain and decryptChain,
+// so the modes only implement the chaining.
+type chainMode interface {
+	// Encrypt processes whole blocks of src into dst.
+	encrypt(a *AES256, dst, src []byte)
+
+	// Decrypt processes whole blocks of src into dst.
+	// Dst and src can't overlap.
+	decrypt(a *AES256, dst, src []byte)
+}
+
+// EcbMode encrypts every block independently.
+type ecbMode struct{}
+
+func (ecbMode) encrypt(a *AES256, dst, src []byte) {
+	// ECB blocks are independent, so the core
+	// can process several of them at once.
+	a.encryptBlocks(dst, src)
+}
+
+func (ecbMode) decrypt(a *AES256, dst, src []byte) {
+	a.decryptBlocks(dst, src)
+}
+
+// CbcMode masks every plainText block with the previous cipherText block.
+type cbcMode struct {
+	iv []byte
+}
+
+func (m cbcMode) encrypt(a *AES256, dst, src []byte) {
+	a.cbcEncrypt(dst, src, m.iv)
+}
+
+func (m cbcMode) decrypt(a *AES256, dst, src []byte) {
+	a.cbcDecrypt(dst, src, m.iv)
+}
+
+// PcbcMode masks every plainText block with both the previous
+// plainText and the previous cipherText block.
+type pcbcMode struct {
+	iv []byte
+}
+
+func (m pcbcMode) encrypt(a *AES256, dst, src []byte) {
+	mask := make([]byte, consts.BLOCK_SIZE)
+	copy(mask, m.iv)
+
+	for i := 0; i < len(src); i += consts.BLOCK_SIZE {
+		block := dst[i : i+consts.BLOCK_SIZE]
+
+		for j := range block {
+			block[j] = src[i+j] ^ mask[j]
+		}
+
+		a.encryptBlocks(block, block)
+
+		// The next mask is P_i ^ C_i.
+		for j := range mask {
+			mask[j] = src[i+j] ^ block[j]
+		}
+	}
+}
+
+func (m pcbcMode) decrypt(a *AES256, dst, src []byte) {
+	// Every block is decrypted independently of the
+	// others, so only the unmasking has to be sequential.
+	a.decryptBlocks(dst, src)
+
+	mask := make([]byte, consts.BLOCK_SIZE)
+	copy(mask, m.iv)
+
+	for i := 0; i < len(src); i += consts.BLOCK_SIZE {
+		block := dst[i : i+consts.BLOCK_SIZE]
+
+		for j := range block {
+			block[j] ^= mask[j]
+			mask[j] = block[j] ^ src[i+j]
+		}
+	}
+}
+
+// EncryptChain pads the plainText and encrypts it in the given mode.
+// The header (like the IV) is prepended to the cipherText.
+func (a *AES256) encryptChain(m chainMode, header []byte, plainText []byte, pad padding.Pad) ([]byte, error) {
+	paddedPlain := pad(plainText)
+
+	if len(paddedPlain)%consts.BLOCK_SIZE != 0 {
+		return nil, errors.New("plainText size not matching the block size")
+	}
+
+	cipherText := make([]byte, len(header)+len(paddedPlain))
+
+	copy(cipherText, header)
+	m.encrypt(a, cipherText[len(header):], paddedPlain)
+
+	return cipherText, nil
+}
+
+// DecryptChain decrypts the cipherText, with the header already
+// removed, in the given mode and unpads the result. Malformed
+// padding is reported as an error.
+func (a *AES256) decryptChain(m chainMode, cipherText []byte, unpad padding.UnPad) ([]byte, error) {
+	// Padding always adds at least one byte,
+	// so a valid cipherText is never empty.
+	if len(cipherText) == 0 || len(cipherText)%consts.BLOCK_SIZE != 0 {
+		return nil, errors.New("cipherText size not matching the block size")
+	}
+
+	paddedPlain := make([]byte, len(cipherText))
+	m.decrypt(a, paddedPlain, cipherText)
+
+	plainText, err := unpad(paddedPlain)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return plainText, nil
+}
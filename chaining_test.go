@@ -0,0 +1,112 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+	"github.com/wedkarz02/aes256go/src/padding"
+)
+
+// ChainModes lists every chaining mode with a fixed IV.
+func chainModes() map[string]chainMode {
+	iv := make([]byte, consts.IV_SIZE)
+	for i := range iv {
+		iv[i] = byte(i)
+	}
+
+	return map[string]chainMode{
+		"ECB":  ecbMode{},
+		"CBC":  cbcMode{iv: iv},
+		"PCBC": pcbcMode{iv: iv},
+	}
+}
+
+func TestChainModes(t *testing.T) {
+	a, err := NewAES256([]byte("chaining key"))
+	if err != nil {
+		panic(err)
+	}
+
+	header := []byte("header")
+
+	for name, m := range chainModes() {
+		for _, pad := range []struct {
+			pad   padding.Pad
+			unpad padding.UnPad
+		}{
+			{padding.PKCS7Padding, padding.PKCS7Unpadding},
+			{padding.ZeroPadding, padding.ZeroUnpadding},
+		} {
+			for _, length := range []int{0, 1, 15, 16, 17, 32, 50} {
+				plainText := bytes.Repeat([]byte{0x5a}, length)
+
+				cipherText, err := a.encryptChain(m, header, plainText, pad.pad)
+				if err != nil {
+					panic(err)
+				}
+
+				if !bytes.Equal(cipherText[:len(header)], header) {
+					t.Fatalf("FAILED: %s header missing for %d bytes", name, length)
+				}
+
+				actual, err := a.decryptChain(m, cipherText[len(header):], pad.unpad)
+				if err != nil {
+					panic(err)
+				}
+
+				if !bytes.Equal(actual, plainText) {
+					t.Fatalf("FAILED: %s round trip failed for %d bytes", name, length)
+				}
+			}
+		}
+
+		// Padding functions which do not fill the last
+		// block are rejected instead of panicking.
+		noPad := func(data []byte) []byte { return data }
+		noUnpad := func(data []byte) ([]byte, error) { return data, nil }
+
+		if _, err := a.encryptChain(m, nil, make([]byte, consts.BLOCK_SIZE+1), noPad); err == nil {
+			t.Fatalf("FAILED: %s encrypted a partial block", name)
+		}
+
+		if _, err := a.decryptChain(m, make([]byte, consts.BLOCK_SIZE+1), noUnpad); err == nil {
+			t.Fatalf("FAILED: %s decrypted a partial block", name)
+		}
+
+		// Padding always adds at least one byte.
+		if _, err := a.decryptChain(m, nil, padding.PKCS7Unpadding); err == nil {
+			t.Fatalf("FAILED: %s empty cipherText accepted", name)
+		}
+
+		// Malformed padding is reported instead of panicking.
+		cipherText, err := a.encryptChain(m, nil, bytes.Repeat([]byte{0x5a}, consts.BLOCK_SIZE), noPad)
+		if err != nil {
+			panic(err)
+		}
+
+		if _, err := a.decryptChain(m, cipherText, padding.PKCS7Unpadding); err == nil {
+			t.Fatalf("FAILED: %s invalid padding accepted", name)
+		}
+	}
+}
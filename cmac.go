@@ -0,0 +1,175 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"crypto/subtle"
+	"hash"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+)
+
+// Minimum length of a truncated CMAC tag accepted by VerifyCMAC.
+const cmacMinTagSize = 8
+
+// Cmac calculates the CMAC (OMAC1) of the data written to it.
+//
+// https://nvlpubs.nist.gov/nistpubs/SpecialPublications/NIST.SP.800-38b.pdf
+type cmac struct {
+	cipher *AES256
+	k1     []byte
+	k2     []byte
+	x      []byte
+	buf    []byte
+}
+
+// NewCMAC returns a hash.Hash calculating the CMAC of the data
+// written to it with the cipher's key. The MAC is 16 bytes long.
+//
+// https://www.rfc-editor.org/rfc/rfc4493
+func (a *AES256) NewCMAC() hash.Hash {
+	return newCMAC(a)
+}
+
+// CMAC calculates the CMAC of the data.
+//
+// https://www.rfc-editor.org/rfc/rfc4493
+func (a *AES256) CMAC(data []byte) []byte {
+	mac := newCMAC(a)
+	mac.Write(data)
+
+	return mac.Sum(nil)
+}
+
+// VerifyCMAC reports whether tag is a valid CMAC of the data. The tag
+// can be truncated to no less than 8 bytes, in which case only the most
+// significant bytes of the MAC are compared. The comparison is done
+// in constant time.
+//
+// https://www.rfc-editor.org/rfc/rfc4493#section-2.5
+func (a *AES256) VerifyCMAC(data []byte, tag []byte) bool {
+	if len(tag) < cmacMinTagSize || len(tag) > consts.BLOCK_SIZE {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare(a.CMAC(data)[:len(tag)], tag) == 1
+}
+
+func newCMAC(a *AES256) *cmac {
+	k1 := make([]byte, consts.BLOCK_SIZE)
+	a.encryptBlocks(k1, k1)
+
+	k1 = dbl(k1)
+	k2 := dbl(k1)
+
+	return &cmac{
+		cipher: a,
+		k1:     k1,
+		k2:     k2,
+		x:      make([]byte, consts.BLOCK_SIZE),
+		buf:    make([]byte, 0, consts.BLOCK_SIZE),
+	}
+}
+
+// Dbl multiplies the block by x in GF(2^128)
+// defined by x^128 + x^7 + x^2 + x + 1.
+func dbl(block []byte) []byte {
+	out := make([]byte, consts.BLOCK_SIZE)
+	carry := block[0] >> 7
+
+	for i := 0; i < consts.BLOCK_SIZE-1; i++ {
+		out[i] = block[i]<<1 | block[i+1]>>7
+	}
+
+	// Constant time conditional reduction.
+	out[consts.BLOCK_SIZE-1] = block[consts.BLOCK_SIZE-1]<<1 ^ 0x87&-carry
+
+	return out
+}
+
+// Write chains the data into the MAC. The last block is
+// kept in the buffer, since it is processed differently.
+func (c *cmac) Write(p []byte) (int, error) {
+	n := len(p)
+
+	for len(p) > 0 {
+		if len(c.buf) == consts.BLOCK_SIZE {
+			for i, b := range c.buf {
+				c.x[i] ^= b
+			}
+
+			c.cipher.encryptBlocks(c.x, c.x)
+			c.buf = c.buf[:0]
+		}
+
+		k := copy(c.buf[len(c.buf):consts.BLOCK_SIZE], p)
+		c.buf = c.buf[:len(c.buf)+k]
+		p = p[k:]
+	}
+
+	return n, nil
+}
+
+// Sum appends the MAC of the data written so far to in.
+// It does not change the underlying state.
+func (c *cmac) Sum(in []byte) []byte {
+	x := make([]byte, consts.BLOCK_SIZE)
+	copy(x, c.x)
+
+	for i, b := range c.buf {
+		x[i] ^= b
+	}
+
+	// A complete last block is masked with K1, an incomplete
+	// one is padded with 10^i and masked with K2.
+	subKey := c.k1
+	if len(c.buf) < consts.BLOCK_SIZE {
+		x[len(c.buf)] ^= 0x80
+		subKey = c.k2
+	}
+
+	for i, b := range subKey {
+		x[i] ^= b
+	}
+
+	c.cipher.encryptBlocks(x, x)
+
+	return append(in, x...)
+}
+
+// Reset clears the data written so far.
+func (c *cmac) Reset() {
+	for i := range c.x {
+		c.x[i] = 0x00
+	}
+
+	c.buf = c.buf[:0]
+}
+
+// Size returns the length of the MAC in bytes.
+func (c *cmac) Size() int {
+	return consts.BLOCK_SIZE
+}
+
+// BlockSize returns the block size of the underlying cipher.
+func (c *cmac) BlockSize() int {
+	return consts.BLOCK_SIZE
+}
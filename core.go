@@ -0,0 +1,254 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/wedkarz02/aes256go/src/bitslice"
+	"github.com/wedkarz02/aes256go/src/consts"
+	"github.com/wedkarz02/aes256go/src/key"
+	t "github.com/wedkarz02/aes256go/src/ttable"
+)
+
+// Core selects the implementation of the block cipher.
+type Core int
+
+const (
+	// CoreTable uses precomputed 32 bit lookup tables.
+	// It is the fastest pure Go core, but the table lookups
+	// depend on the data, so the key can leak through cache
+	// timing on machines shared with an attacker.
+	CoreTable Core = iota
+
+	// CoreBitsliced evaluates the cipher as a boolean circuit
+	// without any secret dependent branches or memory indexing.
+	// It is slower than CoreTable, but safe to use in
+	// multi-tenant environments.
+	CoreBitsliced
+
+	// CoreAESNI uses the AES instructions of x86-64 processors.
+	// It is both the fastest and constant time, but only
+	// available on amd64 CPUs supporting AES-NI.
+	CoreAESNI
+)
+
+// Option configures the cipher created by NewAES256 or NewAES256FromKey.
+type Option func(*AES256) error
+
+// WithCore selects the block cipher implementation.
+// CoreAESNI is used by default when the CPU supports it,
+// CoreTable otherwise.
+func WithCore(c Core) Option {
+	return func(a *AES256) error {
+		switch c {
+		case CoreTable, CoreBitsliced:
+			a.core = c
+			return nil
+		case CoreAESNI:
+			if !supportsAESNI {
+				return errors.New("AES-NI not supported")
+			}
+
+			a.core = c
+			return nil
+		}
+
+		return errors.New("invalid block cipher core")
+	}
+}
+
+// DefaultCore returns the fastest core available.
+func defaultCore() Core {
+	if supportsAESNI {
+		return CoreAESNI
+	}
+
+	return CoreTable
+}
+
+// InitCore prepares the round keys in the format used
+// by the selected core.
+func (a *AES256) initCore() {
+	switch a.core {
+	case CoreBitsliced:
+		a.bsKey = bitslice.RoundKeys(a.expandedKey)
+	case CoreAESNI:
+		a.niEncKey, a.niDecKey = expandKeyNI(a.Key, a.rounds)
+	default:
+		a.encKey, a.decKey = newWordKeys(a.expandedKey)
+	}
+}
+
+// EncryptBlocks encrypts a whole number of blocks from src to dst.
+func (a *AES256) encryptBlocks(dst, src []byte) {
+	switch a.core {
+	case CoreAESNI:
+		encryptBlocksNI(a.rounds, a.niEncKey, dst, src)
+	case CoreBitsliced:
+		for i := 0; i < len(src); i += bitslice.LANES * consts.BLOCK_SIZE {
+			j := i + bitslice.LANES*consts.BLOCK_SIZE
+			if j > len(src) {
+				j = len(src)
+			}
+
+			bitslice.Encrypt(a.bsKey, dst[i:j], src[i:j])
+		}
+	default:
+		for i := 0; i < len(src); i += consts.BLOCK_SIZE {
+			encryptBlockGo(a.encKey, dst[i:], src[i:])
+		}
+	}
+}
+
+// DecryptBlocks decrypts a whole number of blocks from src to dst.
+func (a *AES256) decryptBlocks(dst, src []byte) {
+	switch a.core {
+	case CoreAESNI:
+		decryptBlocksNI(a.rounds, a.niDecKey, dst, src)
+	case CoreBitsliced:
+		for i := 0; i < len(src); i += bitslice.LANES * consts.BLOCK_SIZE {
+			j := i + bitslice.LANES*consts.BLOCK_SIZE
+			if j > len(src) {
+				j = len(src)
+			}
+
+			bitslice.Decrypt(a.bsKey, dst[i:j], src[i:j])
+		}
+	default:
+		for i := 0; i < len(src); i += consts.BLOCK_SIZE {
+			decryptBlockGo(a.decKey, dst[i:], src[i:])
+		}
+	}
+}
+
+// NewWordKeys converts the expanded key to 32 bit words used by
+// the table driven core. Decryption keys are stored in the reverse
+// order with InvMixColumns applied to the inner rounds, as required
+// by the equivalent inverse cipher.
+//
+// https://csrc.nist.gov/pubs/fips/197/final (section 5.3.5)
+func newWordKeys(xKey key.ExpandedKey) ([]uint32, []uint32) {
+	n := len(xKey) / consts.WORD_SIZE
+	encKey := make([]uint32, n)
+	decKey := make([]uint32, n)
+
+	for i := range encKey {
+		encKey[i] = binary.BigEndian.Uint32(xKey[consts.WORD_SIZE*i:])
+	}
+
+	for i := 0; i < n; i += consts.NB {
+		ei := n - i - consts.NB
+
+		for j := 0; j < consts.NB; j++ {
+			w := encKey[ei+j]
+
+			if i > 0 && i+consts.NB < n {
+				w = t.InvMixWord(w)
+			}
+
+			decKey[i+j] = w
+		}
+	}
+
+	return encKey, decKey
+}
+
+// EncryptBlockGo encrypts one block from src to dst using
+// the T-table round function.
+//
+// https://en.wikipedia.org/wiki/Advanced_Encryption_Standard#Optimization_of_the_cipher
+func encryptBlockGo(xk []uint32, dst, src []byte) {
+	_ = src[15]
+	s0 := binary.BigEndian.Uint32(src[0:4]) ^ xk[0]
+	s1 := binary.BigEndian.Uint32(src[4:8]) ^ xk[1]
+	s2 := binary.BigEndian.Uint32(src[8:12]) ^ xk[2]
+	s3 := binary.BigEndian.Uint32(src[12:16]) ^ xk[3]
+
+	nr := len(xk)/consts.NB - 1
+	k := consts.NB
+
+	var t0, t1, t2, t3 uint32
+
+	for r := 1; r < nr; r++ {
+		t0 = xk[k+0] ^ t.Te0[s0>>24] ^ t.Te1[s1>>16&0xff] ^ t.Te2[s2>>8&0xff] ^ t.Te3[s3&0xff]
+		t1 = xk[k+1] ^ t.Te0[s1>>24] ^ t.Te1[s2>>16&0xff] ^ t.Te2[s3>>8&0xff] ^ t.Te3[s0&0xff]
+		t2 = xk[k+2] ^ t.Te0[s2>>24] ^ t.Te1[s3>>16&0xff] ^ t.Te2[s0>>8&0xff] ^ t.Te3[s1&0xff]
+		t3 = xk[k+3] ^ t.Te0[s3>>24] ^ t.Te1[s0>>16&0xff] ^ t.Te2[s1>>8&0xff] ^ t.Te3[s2&0xff]
+		k += consts.NB
+
+		s0, s1, s2, s3 = t0, t1, t2, t3
+	}
+
+	// The last round skips MixColumns.
+	sb := t.SBOX
+	t0 = t.Word(sb[s0>>24], sb[s1>>16&0xff], sb[s2>>8&0xff], sb[s3&0xff]) ^ xk[k+0]
+	t1 = t.Word(sb[s1>>24], sb[s2>>16&0xff], sb[s3>>8&0xff], sb[s0&0xff]) ^ xk[k+1]
+	t2 = t.Word(sb[s2>>24], sb[s3>>16&0xff], sb[s0>>8&0xff], sb[s1&0xff]) ^ xk[k+2]
+	t3 = t.Word(sb[s3>>24], sb[s0>>16&0xff], sb[s1>>8&0xff], sb[s2&0xff]) ^ xk[k+3]
+
+	_ = dst[15]
+	binary.BigEndian.PutUint32(dst[0:4], t0)
+	binary.BigEndian.PutUint32(dst[4:8], t1)
+	binary.BigEndian.PutUint32(dst[8:12], t2)
+	binary.BigEndian.PutUint32(dst[12:16], t3)
+}
+
+// DecryptBlockGo decrypts one block from src to dst using
+// the T-table round function and the equivalent inverse cipher.
+//
+// https://en.wikipedia.org/wiki/Advanced_Encryption_Standard#Optimization_of_the_cipher
+func decryptBlockGo(xk []uint32, dst, src []byte) {
+	_ = src[15]
+	s0 := binary.BigEndian.Uint32(src[0:4]) ^ xk[0]
+	s1 := binary.BigEndian.Uint32(src[4:8]) ^ xk[1]
+	s2 := binary.BigEndian.Uint32(src[8:12]) ^ xk[2]
+	s3 := binary.BigEndian.Uint32(src[12:16]) ^ xk[3]
+
+	nr := len(xk)/consts.NB - 1
+	k := consts.NB
+
+	var t0, t1, t2, t3 uint32
+
+	for r := 1; r < nr; r++ {
+		t0 = xk[k+0] ^ t.Td0[s0>>24] ^ t.Td1[s3>>16&0xff] ^ t.Td2[s2>>8&0xff] ^ t.Td3[s1&0xff]
+		t1 = xk[k+1] ^ t.Td0[s1>>24] ^ t.Td1[s0>>16&0xff] ^ t.Td2[s3>>8&0xff] ^ t.Td3[s2&0xff]
+		t2 = xk[k+2] ^ t.Td0[s2>>24] ^ t.Td1[s1>>16&0xff] ^ t.Td2[s0>>8&0xff] ^ t.Td3[s3&0xff]
+		t3 = xk[k+3] ^ t.Td0[s3>>24] ^ t.Td1[s2>>16&0xff] ^ t.Td2[s1>>8&0xff] ^ t.Td3[s0&0xff]
+		k += consts.NB
+
+		s0, s1, s2, s3 = t0, t1, t2, t3
+	}
+
+	// The last round skips InvMixColumns.
+	isb := t.InvSBOX
+	t0 = t.Word(isb[s0>>24], isb[s3>>16&0xff], isb[s2>>8&0xff], isb[s1&0xff]) ^ xk[k+0]
+	t1 = t.Word(isb[s1>>24], isb[s0>>16&0xff], isb[s3>>8&0xff], isb[s2&0xff]) ^ xk[k+1]
+	t2 = t.Word(isb[s2>>24], isb[s1>>16&0xff], isb[s0>>8&0xff], isb[s3&0xff]) ^ xk[k+2]
+	t3 = t.Word(isb[s3>>24], isb[s2>>16&0xff], isb[s1>>8&0xff], isb[s0&0xff]) ^ xk[k+3]
+
+	_ = dst[15]
+	binary.BigEndian.PutUint32(dst[0:4], t0)
+	binary.BigEndian.PutUint32(dst[4:8], t1)
+	binary.BigEndian.PutUint32(dst[8:12], t2)
+	binary.BigEndian.PutUint32(dst[12:16], t3)
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+)
+
+func TestTableCoreMatchesReference(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, size := range []int{consts.KEY_SIZE_128, consts.KEY_SIZE_192, consts.KEY_SIZE_256} {
+		testKey := make([]byte, size)
+		rng.Read(testKey)
+
+		// AES-NI is the default where available, the table
+		// core has to be requested to be the one tested.
+		a, err := NewAES256FromKey(testKey, WithCore(CoreTable))
+		if err != nil {
+			panic(err)
+		}
+
+		block := make([]byte, consts.BLOCK_SIZE)
+
+		for i := 0; i < 100; i++ {
+			rng.Read(block)
+
+			expected, err := a.encryptBlockRef(block)
+			if err != nil {
+				panic(err)
+			}
+
+			actual, err := a.EncryptBlock(block)
+			if err != nil {
+				panic(err)
+			}
+
+			if !bytes.Equal(actual, expected) {
+				t.Fatalf("FAILED: table encryption does not match reference for %d byte key", size)
+			}
+
+			expected, err = a.decryptBlockRef(block)
+			if err != nil {
+				panic(err)
+			}
+
+			actual, err = a.DecryptBlock(block)
+			if err != nil {
+				panic(err)
+			}
+
+			if !bytes.Equal(actual, expected) {
+				t.Fatalf("FAILED: table decryption does not match reference for %d byte key", size)
+			}
+		}
+	}
+}
+
+func newBenchmarkCipher(b *testing.B) *AES256 {
+	a, err := NewAES256FromKey(make([]byte, consts.KEY_SIZE), WithCore(CoreTable))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(consts.BLOCK_SIZE)
+	b.ResetTimer()
+
+	return a
+}
+
+func BenchmarkEncryptBlockRef(b *testing.B) {
+	a := newBenchmarkCipher(b)
+	block := make([]byte, consts.BLOCK_SIZE)
+
+	for i := 0; i < b.N; i++ {
+		block, _ = a.encryptBlockRef(block)
+	}
+}
+
+func BenchmarkDecryptBlockRef(b *testing.B) {
+	a := newBenchmarkCipher(b)
+	block := make([]byte, consts.BLOCK_SIZE)
+
+	for i := 0; i < b.N; i++ {
+		block, _ = a.decryptBlockRef(block)
+	}
+}
+
+func BenchmarkEncryptBlock(b *testing.B) {
+	a := newBenchmarkCipher(b)
+	block := make([]byte, consts.BLOCK_SIZE)
+
+	for i := 0; i < b.N; i++ {
+		block, _ = a.EncryptBlock(block)
+	}
+}
+
+func BenchmarkDecryptBlock(b *testing.B) {
+	a := newBenchmarkCipher(b)
+	block := make([]byte, consts.BLOCK_SIZE)
+
+	for i := 0; i < b.N; i++ {
+		block, _ = a.DecryptBlock(block)
+	}
+}
+
+func BenchmarkEncrypt(b *testing.B) {
+	a := newBenchmarkCipher(b)
+	block := make([]byte, consts.BLOCK_SIZE)
+
+	for i := 0; i < b.N; i++ {
+		a.Encrypt(block, block)
+	}
+}
+
+func BenchmarkDecrypt(b *testing.B) {
+	a := newBenchmarkCipher(b)
+	block := make([]byte, consts.BLOCK_SIZE)
+
+	for i := 0; i < b.N; i++ {
+		a.Decrypt(block, block)
+	}
+}
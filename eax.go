@@ -0,0 +1,217 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+	"github.com/wedkarz02/aes256go/src/counter"
+)
+
+// Eax implements the cipher.AEAD interface in EAX mode.
+type eax struct {
+	cipher    *AES256
+	nonceSize int
+	tagSize   int
+}
+
+// NewEAX returns the cipher wrapped in EAX mode as a cipher.AEAD
+// with the given nonce and tag sizes.
+//
+// EAX accepts nonces of any length, although there is little point
+// in using more than 16 bytes. The tag has to be 1 to 16 bytes long.
+//
+// https://web.cs.ucdavis.edu/~rogaway/papers/eax.pdf
+func (a *AES256) NewEAX(nonceSize, tagSize int) (cipher.AEAD, error) {
+	if nonceSize < 1 {
+		return nil, errors.New("invalid nonce size")
+	}
+
+	if tagSize < 1 || tagSize > consts.TAG_SIZE {
+		return nil, errors.New("invalid tag size")
+	}
+
+	return &eax{cipher: a, nonceSize: nonceSize, tagSize: tagSize}, nil
+}
+
+// Data encryption and authentication using EAX mode with a random 16 byte
+// nonce. Nonce is prepended to the cipherText and the 16 byte authentication
+// tag is appended to the cipherText.
+//
+// Both plainText and authData will be authenticated, but only plainText is encrypted.
+//
+// https://web.cs.ucdavis.edu/~rogaway/papers/eax.pdf
+func (a *AES256) EncryptEAX(plainText []byte, authData []byte) ([]byte, error) {
+	aead, err := a.NewEAX(consts.BLOCK_SIZE, consts.TAG_SIZE)
+
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plainText)+aead.Overhead())
+	if err := readRandom(a.rand, nonce, "nonce"); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plainText, authData), nil
+}
+
+// Data decryption and authentication using EAX mode. The 16 byte nonce
+// is prepended to the cipherText and the 16 byte authentication tag
+// is appended to the cipherText.
+//
+// Both cipherText and authData will be authenticated, but only cipherText is decrypted.
+//
+// https://web.cs.ucdavis.edu/~rogaway/papers/eax.pdf
+func (a *AES256) DecryptEAX(cipherText []byte, authData []byte) ([]byte, error) {
+	aead, err := a.NewEAX(consts.BLOCK_SIZE, consts.TAG_SIZE)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cipherText) < aead.NonceSize()+aead.Overhead() {
+		return nil, errors.New("EAX authentication failed: cipherText too short")
+	}
+
+	nonce := cipherText[:aead.NonceSize()]
+	return aead.Open(nil, nonce, cipherText[aead.NonceSize():], authData)
+}
+
+func (e *eax) NonceSize() int {
+	return e.nonceSize
+}
+
+func (e *eax) Overhead() int {
+	return e.tagSize
+}
+
+// Seal encrypts and authenticates plaintext, authenticates
+// additionalData and appends the result to dst.
+//
+// To reuse plaintext's storage for the output, use plaintext[:0] as dst.
+func (e *eax) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != e.nonceSize {
+		panic("aes256go: incorrect nonce length given to EAX")
+	}
+
+	ret, out := sliceForAppend(dst, len(plaintext)+e.tagSize)
+
+	if inexactOverlap(out, plaintext) {
+		panic("aes256go: invalid buffer overlap")
+	}
+
+	mac := newCMAC(e.cipher)
+	n := e.omac(mac, 0, nonce)
+	h := e.omac(mac, 1, additionalData)
+
+	cipherText, err := e.cipher.coreBlockCTR(plaintext, newEAXCounter(n))
+
+	if err != nil {
+		panic(err)
+	}
+
+	c := e.omac(mac, 2, cipherText)
+
+	copy(out, cipherText)
+
+	for i := 0; i < e.tagSize; i++ {
+		out[len(plaintext)+i] = n[i] ^ h[i] ^ c[i]
+	}
+
+	return ret
+}
+
+// Open decrypts and authenticates ciphertext, authenticates
+// additionalData and, if successful, appends the resulting
+// plaintext to dst.
+//
+// To reuse ciphertext's storage for the output, use ciphertext[:0] as dst.
+func (e *eax) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != e.nonceSize {
+		panic("aes256go: incorrect nonce length given to EAX")
+	}
+
+	if len(ciphertext) < e.tagSize {
+		return nil, errors.New("EAX authentication failed: cipherText too short")
+	}
+
+	tag := ciphertext[len(ciphertext)-e.tagSize:]
+	ciphertext = ciphertext[:len(ciphertext)-e.tagSize]
+
+	ret, out := sliceForAppend(dst, len(ciphertext))
+
+	if inexactOverlap(out, ciphertext) {
+		panic("aes256go: invalid buffer overlap")
+	}
+
+	mac := newCMAC(e.cipher)
+	n := e.omac(mac, 0, nonce)
+	h := e.omac(mac, 1, additionalData)
+	c := e.omac(mac, 2, ciphertext)
+
+	testTag := make([]byte, e.tagSize)
+	for i := range testTag {
+		testTag[i] = n[i] ^ h[i] ^ c[i]
+	}
+
+	// The tag is checked before anything is decrypted,
+	// so no unauthenticated plaintext is ever released.
+	if subtle.ConstantTimeCompare(tag, testTag) != 1 {
+		return nil, errors.New("EAX authentication failed: Invalid authentication tag")
+	}
+
+	plainText, err := e.cipher.coreBlockCTR(ciphertext, newEAXCounter(n))
+
+	if err != nil {
+		return nil, err
+	}
+
+	copy(out, plainText)
+
+	return ret, nil
+}
+
+// Omac calculates OMAC^t(data) = CMAC([t]_128 || data).
+func (e *eax) omac(mac *cmac, t byte, data []byte) []byte {
+	block := make([]byte, consts.BLOCK_SIZE)
+	block[consts.BLOCK_SIZE-1] = t
+
+	mac.Reset()
+	mac.Write(block)
+	mac.Write(data)
+
+	return mac.Sum(nil)
+}
+
+// NewEAXCounter returns a full 128 bit counter starting at N.
+func newEAXCounter(n []byte) *counter.Counter {
+	ctr, err := counter.NewCounterBlock(n, consts.BLOCK_SIZE)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return ctr
+}
@@ -0,0 +1,236 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+)
+
+// Test vectors from "The EAX Mode of Operation" by Bellare, Rogaway
+// and Wagner. They use 128 bit keys, which this package supports
+// when the cipher is created from a raw key.
+var eaxTests = []struct {
+	key        string
+	nonce      string
+	authData   string
+	plainText  string
+	cipherText string
+}{
+	{
+		key:        "233952dee4d5ed5f9b9c6d6ff80ff478",
+		nonce:      "62ec67f9c3a4a407fcb2a8c49031a8b3",
+		authData:   "6bfb914fd07eae6b",
+		cipherText: "e037830e8389f27b025a2d6527e79d01",
+	},
+	{
+		key:        "91945d3f4dcbee0bf45ef52255f095a4",
+		nonce:      "becaf043b0a23d843194ba972c66debd",
+		authData:   "fa3bfd4806eb53fa",
+		plainText:  "f7fb",
+		cipherText: "19dd5c4c9331049d0bdab0277408f67967e5",
+	},
+	{
+		key:        "01f74ad64077f2e704c0f60ada3dd523",
+		nonce:      "70c3db4f0d26368400a10ed05d2bff5e",
+		authData:   "234a3463c1264ac6",
+		plainText:  "1a47cb4933",
+		cipherText: "d851d5bae03a59f238a23e39199dc9266626c40f80",
+	},
+	{
+		key:        "d07cf6cbb7f313bdde66b727afd3c5e8",
+		nonce:      "8408dfff3c1a2b1292dc199e46b7d617",
+		authData:   "33cce2eabff5a79d",
+		plainText:  "481c9e39b1",
+		cipherText: "632a9d131ad4c168a4225d8e1ff755939974a7bede",
+	},
+	{
+		key:        "35b6d0580005bbc12b0587124557d2c2",
+		nonce:      "fdb6b06676eedc5c61d74276e1f8e816",
+		authData:   "aeb96eaebe2970e9",
+		plainText:  "40d0c07da5e4",
+		cipherText: "071dfe16c675cb0677e536f73afe6a14b74ee49844dd",
+	},
+}
+
+func TestEAXVectors(t *testing.T) {
+	for i, test := range eaxTests {
+		a, err := NewAES256FromKey(decodeHex(test.key))
+		if err != nil {
+			panic(err)
+		}
+
+		nonce := decodeHex(test.nonce)
+		expected := append(decodeHex(test.nonce), decodeHex(test.cipherText)...)
+
+		aead, err := a.NewEAX(len(nonce), consts.TAG_SIZE)
+		if err != nil {
+			panic(err)
+		}
+
+		actual := aead.Seal(decodeHex(test.nonce), nonce, decodeHex(test.plainText), decodeHex(test.authData))
+
+		if !bytes.Equal(actual, expected) {
+			t.Fatalf("FAILED: EAX test vector %d encryption failed", i+1)
+		}
+
+		plainText, err := a.DecryptEAX(expected, decodeHex(test.authData))
+		if err != nil || !bytes.Equal(plainText, decodeHex(test.plainText)) {
+			t.Fatalf("FAILED: EAX test vector %d decryption failed", i+1)
+		}
+
+		expected[len(expected)-1] ^= 0x01
+
+		if _, err := a.DecryptEAX(expected, decodeHex(test.authData)); err == nil {
+			t.Fatalf("FAILED: EAX test vector %d accepted a forged tag", i+1)
+		}
+	}
+}
+
+func TestEAXParameters(t *testing.T) {
+	a, err := NewAES256FromKey(bytes.Repeat([]byte{0x0e}, consts.KEY_SIZE))
+	if err != nil {
+		panic(err)
+	}
+
+	plainText := bytes.Repeat([]byte("legacy peer "), 10)
+	authData := []byte("header")
+
+	for _, nonceSize := range []int{1, 7, 12, 16, 17, 32, 100} {
+		for _, tagSize := range []int{1, 8, 12, consts.TAG_SIZE} {
+			aead, err := a.NewEAX(nonceSize, tagSize)
+			if err != nil {
+				t.Fatalf("FAILED: EAX(%d, %d) rejected: %v", nonceSize, tagSize, err)
+			}
+
+			nonce := bytes.Repeat([]byte{byte(nonceSize)}, nonceSize)
+			full, err := a.NewEAX(nonceSize, consts.TAG_SIZE)
+			if err != nil {
+				panic(err)
+			}
+
+			for _, size := range []int{0, 1, 16, 17, len(plainText)} {
+				sealed := aead.Seal(nil, nonce, plainText[:size], authData)
+
+				// A truncated tag is a prefix of the full one.
+				if !bytes.Equal(sealed, full.Seal(nil, nonce, plainText[:size], authData)[:size+tagSize]) {
+					t.Fatalf("FAILED: EAX(%d, %d) tag is not a prefix of the full tag", nonceSize, tagSize)
+				}
+
+				opened, err := aead.Open(nil, nonce, sealed, authData)
+				if err != nil || !bytes.Equal(opened, plainText[:size]) {
+					t.Fatalf("FAILED: EAX(%d, %d) round trip failed for %d bytes", nonceSize, tagSize, size)
+				}
+
+				if _, err := aead.Open(nil, nonce, sealed, authData[1:]); err == nil {
+					t.Fatalf("FAILED: EAX(%d, %d) accepted wrong additional data", nonceSize, tagSize)
+				}
+			}
+		}
+	}
+
+	for _, params := range [][2]int{{0, 16}, {16, 0}, {16, 17}} {
+		if _, err := a.NewEAX(params[0], params[1]); err == nil {
+			t.Fatalf("FAILED: EAX(%d, %d) accepted", params[0], params[1])
+		}
+	}
+
+	cipherText, err := a.EncryptEAX(plainText, authData)
+	if err != nil {
+		panic(err)
+	}
+
+	decrypted, err := a.DecryptEAX(cipherText, authData)
+	if err != nil || !bytes.Equal(decrypted, plainText) {
+		t.Fatalf("FAILED: EAX round trip failed")
+	}
+
+	if _, err := a.DecryptEAX(cipherText[:consts.BLOCK_SIZE+consts.TAG_SIZE-1], authData); err == nil {
+		t.Fatalf("FAILED: EAX accepted a truncated cipherText")
+	}
+}
+
+// AES-256 test vectors from the randomly generated EAX set published
+// with github.com/ProtonMail/go-crypto (eax/random_vectors.go).
+var eaxTestsAES256 = []struct {
+	key        string
+	nonce      string
+	authData   string
+	plainText  string
+	cipherText string
+}{
+	{
+		key:        "02e59853fb29aeda0fe1c5f19180ad99a12ff2f144670bb2b8badf09ad812e0a",
+		nonce:      "c691294ef67cd04d1b9242af83dd1421",
+		authData:   "879334dae3",
+		plainText:  "1e17f46a98fef5cbb40759d95354",
+		cipherText: "fed8c3ff27ddf6313aed444a2985b36cba268aad6aac563c0ba28f6db5db",
+	},
+	{
+		key:      "6847e0491be57e72995d186d50094b0b3593957a5146798fce68b287b2fb37b5",
+		nonce:    "3ee1182aebb19a02b128f28e1d5f7f99",
+		authData: "d9f35abb16d776ce",
+		plainText: "db7566ed8ea95bdf837f23db277bafbc5e70d1105adfd0d9ef15475051b1ef94" +
+			"709c67dca9f8d5",
+		cipherText: "2cdced0c9ebd6e2a508822a685f7dcd1cdd99e7a5fca786c234e7f7f1d27ec49" +
+			"751ad5dcfa30c5eda87c43cae3b919b6bbcfe34c8eda59",
+	},
+	{
+		key:   "c92f678eb2208662f5bcf3403ec05f5961e957908a3e79421e1d25fc19054153",
+		nonce: "da0f3a40983d92f2d4c01fed33c7a192",
+		authData: "2b6e9d26db406a0fab47608657aa10efc2b4aa5f459b29ff85ac9a40bffe7aeb" +
+			"04f77e9a11faaa116d7f6d4da417671a9ab02c588e0ef59cb1bfb4b1cc931b63" +
+			"a3b3a159fcec97a04d1e6f0c7e6a9cef6b0abb04758a69f1fe754df4c2610e8c" +
+			"46b6cf413bdb31351d55bedcb7b4a13a1c98e10984475e0f2f957853",
+		plainText:  "f37326a80e08",
+		cipherText: "83519e53e321d334f7c10b568183775c0e9aae55f806",
+	},
+}
+
+func TestEAXVectorsAES256(t *testing.T) {
+	for i, test := range eaxTestsAES256 {
+		a, err := NewAES256FromKey(decodeHex(test.key))
+		if err != nil {
+			panic(err)
+		}
+
+		nonce := decodeHex(test.nonce)
+		expected := append(decodeHex(test.nonce), decodeHex(test.cipherText)...)
+
+		aead, err := a.NewEAX(len(nonce), consts.TAG_SIZE)
+		if err != nil {
+			panic(err)
+		}
+
+		actual := aead.Seal(decodeHex(test.nonce), nonce, decodeHex(test.plainText), decodeHex(test.authData))
+
+		if !bytes.Equal(actual, expected) {
+			t.Fatalf("FAILED: EAX AES-256 test vector %d encryption failed", i+1)
+		}
+
+		plainText, err := a.DecryptEAX(expected, decodeHex(test.authData))
+		if err != nil || !bytes.Equal(plainText, decodeHex(test.plainText)) {
+			t.Fatalf("FAILED: EAX AES-256 test vector %d decryption failed", i+1)
+		}
+	}
+}
@@ -89,3 +89,33 @@ func DecryptGCMExample(key []byte, cipherText []byte, authData []byte) []byte {
 
 	return plainText
 }
+
+// This is an example usage of GCM mode through the cipher.AEAD interface.
+func SealGCMExample(key []byte, nonce []byte, plainText []byte, authData []byte) []byte {
+
+	// Cipher object initialization.
+	cipher, err := aes256go.NewAES256(key)
+
+	// It is strongly recommended to wipe the key from memory at the end.
+	defer cipher.ClearKey()
+
+	// Make sure to check for any errors.
+	if err != nil {
+		log.Fatalf("Cipher init error: %v\n", err)
+	}
+
+	// The returned value implements cipher.AEAD from the standard library.
+	aead, err := cipher.NewGCM()
+
+	// Make sure to check for any errors.
+	if err != nil {
+		log.Fatalf("GCM init error: %v\n", err)
+	}
+
+	// The nonce has to be exactly aead.NonceSize() bytes long
+	// and must never be reused with the same key.
+	//
+	// The cipherText is appended to the first argument, with
+	// the authentication tag at the end.
+	return aead.Seal(nil, nonce, plainText, authData)
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+)
+
+// Gcm implements the cipher.AEAD interface on top of
+// the package's GCM primitives.
+type gcm struct {
+	cipher *AES256
+}
+
+// NewGCM returns the cipher wrapped in Galois Counter Mode
+// as a cipher.AEAD with 12 byte nonces and 16 byte tags.
+//
+// Unlike EncryptGCM and DecryptGCM, the caller is responsible
+// for the nonce, which must never be reused with the same key.
+//
+// https://nvlpubs.nist.gov/nistpubs/Legacy/SP/nistspecialpublication800-38d.pdf
+func (a *AES256) NewGCM() (cipher.AEAD, error) {
+	return &gcm{cipher: a}, nil
+}
+
+func (g *gcm) NonceSize() int {
+	return consts.NONCE_SIZE
+}
+
+func (g *gcm) Overhead() int {
+	return consts.TAG_SIZE
+}
+
+// Seal encrypts and authenticates plaintext, authenticates
+// additionalData and appends the result to dst.
+//
+// To reuse plaintext's storage for the output, use plaintext[:0] as dst.
+func (g *gcm) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != consts.NONCE_SIZE {
+		panic("aes256go: incorrect nonce length given to GCM")
+	}
+
+	ret, out := sliceForAppend(dst, len(plaintext)+consts.TAG_SIZE)
+
+	if inexactOverlap(out, plaintext) {
+		panic("aes256go: invalid buffer overlap")
+	}
+
+	ctr, err := newGCMCounter(nonce)
+
+	if err != nil {
+		panic(err)
+	}
+
+	// The counter can only run out for plaintexts longer
+	// than 2^32 - 2 blocks, which GCM does not allow.
+	cipherText, err := g.cipher.coreBlockCTR(plaintext, ctr)
+
+	if err != nil {
+		panic("aes256go: message too large for GCM")
+	}
+
+	tag, err := g.cipher.GMAC(cipherText, additionalData, nonce)
+
+	if err != nil {
+		panic(err)
+	}
+
+	copy(out, cipherText)
+	copy(out[len(plaintext):], tag)
+
+	return ret
+}
+
+// Open decrypts and authenticates ciphertext, authenticates
+// additionalData and, if successful, appends the resulting
+// plaintext to dst.
+//
+// To reuse ciphertext's storage for the output, use ciphertext[:0] as dst.
+func (g *gcm) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != consts.NONCE_SIZE {
+		panic("aes256go: incorrect nonce length given to GCM")
+	}
+
+	if len(ciphertext) < consts.TAG_SIZE {
+		return nil, errors.New("GCM authentication failed: cipherText too short")
+	}
+
+	tag := ciphertext[len(ciphertext)-consts.TAG_SIZE:]
+	ciphertext = ciphertext[:len(ciphertext)-consts.TAG_SIZE]
+
+	ret, out := sliceForAppend(dst, len(ciphertext))
+
+	if inexactOverlap(out, ciphertext) {
+		panic("aes256go: invalid buffer overlap")
+	}
+
+	testTag, err := g.cipher.GMAC(ciphertext, additionalData, nonce)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// The tag is checked before anything is decrypted,
+	// so no unauthenticated plaintext is ever released.
+	if subtle.ConstantTimeCompare(tag, testTag) != 1 {
+		return nil, errors.New("GCM authentication failed: Invalid authentication tag")
+	}
+
+	ctr, err := newGCMCounter(nonce)
+
+	if err != nil {
+		return nil, err
+	}
+
+	plainText, err := g.cipher.coreBlockCTR(ciphertext, ctr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	copy(out, plainText)
+
+	return ret, nil
+}
+
+// SliceForAppend extends the input slice by n bytes. Head is
+// the full extended slice, tail is the appended part. If the
+// original slice has sufficient capacity no allocation is done.
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+
+	tail = head[len(in):]
+	return
+}
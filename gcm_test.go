@@ -0,0 +1,167 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"testing"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+)
+
+func newTestGCM(k []byte) (cipher.AEAD, cipher.AEAD) {
+	a, err := NewAES256FromKey(k)
+	if err != nil {
+		panic(err)
+	}
+
+	aead, err := a.NewGCM()
+	if err != nil {
+		panic(err)
+	}
+
+	ref, err := aes.NewCipher(k)
+	if err != nil {
+		panic(err)
+	}
+
+	refAEAD, err := cipher.NewGCM(ref)
+	if err != nil {
+		panic(err)
+	}
+
+	return aead, refAEAD
+}
+
+func TestGCMAEADInterop(t *testing.T) {
+	aead, ref := newTestGCM(bytes.Repeat([]byte{0x42}, consts.KEY_SIZE))
+
+	if aead.NonceSize() != ref.NonceSize() || aead.Overhead() != ref.Overhead() {
+		t.Fatalf("FAILED: GCM parameters do not match crypto/cipher")
+	}
+
+	nonce := []byte("unique nonce")
+	plainText := bytes.Repeat([]byte("AEAD"), 40)
+	authData := []byte("associated data")
+
+	for _, size := range []int{0, 1, 15, 16, 17, 31, 32, 33, 127, 128, 129, 160} {
+		actual := aead.Seal(nil, nonce, plainText[:size], authData)
+		expected := ref.Seal(nil, nonce, plainText[:size], authData)
+
+		if !bytes.Equal(actual, expected) {
+			t.Fatalf("FAILED: GCM Seal mismatch for %d bytes", size)
+		}
+
+		opened, err := aead.Open(nil, nonce, expected, authData)
+		if err != nil || !bytes.Equal(opened, plainText[:size]) {
+			t.Fatalf("FAILED: GCM Open failed for %d bytes", size)
+		}
+	}
+}
+
+func TestGCMAEADAppend(t *testing.T) {
+	aead, _ := newTestGCM(make([]byte, consts.KEY_SIZE))
+
+	nonce := make([]byte, aead.NonceSize())
+	prefix := []byte("prefix")
+	plainText := []byte("appended to the destination")
+
+	sealed := aead.Seal(append([]byte{}, prefix...), nonce, plainText, nil)
+
+	if !bytes.Equal(sealed[:len(prefix)], prefix) {
+		t.Fatalf("FAILED: Seal overwrote dst")
+	}
+
+	if len(sealed) != len(prefix)+len(plainText)+aead.Overhead() {
+		t.Fatalf("FAILED: Seal returned wrong length")
+	}
+
+	opened, err := aead.Open(append([]byte{}, prefix...), nonce, sealed[len(prefix):], nil)
+	if err != nil {
+		t.Fatalf("FAILED: Open failed: %v", err)
+	}
+
+	if !bytes.Equal(opened, append(append([]byte{}, prefix...), plainText...)) {
+		t.Fatalf("FAILED: Open did not append to dst")
+	}
+}
+
+func TestGCMAEADInPlace(t *testing.T) {
+	aead, ref := newTestGCM(make([]byte, consts.KEY_SIZE))
+
+	nonce := make([]byte, aead.NonceSize())
+	plainText := []byte("encrypted and decrypted in place")
+	expected := ref.Seal(nil, nonce, plainText, nil)
+
+	buf := make([]byte, len(plainText), len(plainText)+aead.Overhead())
+	copy(buf, plainText)
+
+	sealed := aead.Seal(buf[:0], nonce, buf, nil)
+
+	if &sealed[0] != &buf[0] {
+		t.Fatalf("FAILED: Seal did not reuse the plaintext storage")
+	}
+
+	if !bytes.Equal(sealed, expected) {
+		t.Fatalf("FAILED: in place Seal mismatch")
+	}
+
+	opened, err := aead.Open(sealed[:0], nonce, sealed, nil)
+	if err != nil || !bytes.Equal(opened, plainText) {
+		t.Fatalf("FAILED: in place Open failed")
+	}
+
+	if &opened[0] != &buf[0] {
+		t.Fatalf("FAILED: Open did not reuse the ciphertext storage")
+	}
+}
+
+func TestGCMAEADErrors(t *testing.T) {
+	aead, _ := newTestGCM(make([]byte, consts.KEY_SIZE))
+	nonce := make([]byte, aead.NonceSize())
+
+	sealed := aead.Seal(nil, nonce, []byte("message"), []byte("data"))
+
+	if _, err := aead.Open(nil, nonce, sealed, []byte("other data")); err == nil {
+		t.Fatalf("FAILED: Open accepted wrong additional data")
+	}
+
+	if _, err := aead.Open(nil, nonce, sealed[:aead.Overhead()-1], nil); err == nil {
+		t.Fatalf("FAILED: Open accepted truncated ciphertext")
+	}
+
+	for _, fn := range []func(){
+		func() { aead.Seal(nil, nonce[1:], nil, nil) },
+		func() { aead.Open(nil, nonce[1:], sealed, nil) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("FAILED: wrong nonce size did not panic")
+				}
+			}()
+
+			fn()
+		}()
+	}
+}
@@ -0,0 +1,334 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+	g "github.com/wedkarz02/aes256go/src/galois"
+)
+
+// GcmSIV implements the cipher.AEAD interface in
+// nonce misuse-resistant GCM-SIV mode.
+type gcmSIV struct {
+	cipher *AES256
+}
+
+// NewGCMSIV returns the cipher wrapped in AES-GCM-SIV mode as
+// a cipher.AEAD with 12 byte nonces and 16 byte tags.
+//
+// Unlike GCM, a repeated nonce only reveals whether the same
+// message was encrypted twice. Only 16 and 32 byte keys are
+// supported, as AES-192 is not defined for GCM-SIV.
+//
+// https://www.rfc-editor.org/rfc/rfc8452
+func (a *AES256) NewGCMSIV() (cipher.AEAD, error) {
+	if len(a.Key) != consts.KEY_SIZE_128 && len(a.Key) != consts.KEY_SIZE_256 {
+		return nil, errors.New("invalid key size for GCM-SIV")
+	}
+
+	return &gcmSIV{cipher: a}, nil
+}
+
+// Data encryption and authentication using GCM-SIV mode. Nonce is prepended
+// to the cipherText and the authentication tag is appended to the cipherText.
+//
+// Both plainText and authData will be authenticated, but only plainText is encrypted.
+//
+// https://www.rfc-editor.org/rfc/rfc8452
+func (a *AES256) EncryptGCMSIV(plainText []byte, authData []byte) ([]byte, error) {
+	aead, err := a.NewGCMSIV()
+
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plainText)+aead.Overhead())
+	if err := readRandom(a.rand, nonce, "nonce"); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plainText, authData), nil
+}
+
+// Data decryption and authentication using GCM-SIV mode. Nonce is prepended
+// to the cipherText and the authentication tag is appended to the cipherText.
+//
+// Both cipherText and authData will be authenticated, but only cipherText is decrypted.
+//
+// https://www.rfc-editor.org/rfc/rfc8452
+func (a *AES256) DecryptGCMSIV(cipherText []byte, authData []byte) ([]byte, error) {
+	aead, err := a.NewGCMSIV()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cipherText) < aead.NonceSize()+aead.Overhead() {
+		return nil, errors.New("GCM-SIV authentication failed: cipherText too short")
+	}
+
+	nonce := cipherText[:aead.NonceSize()]
+	return aead.Open(nil, nonce, cipherText[aead.NonceSize():], authData)
+}
+
+func (s *gcmSIV) NonceSize() int {
+	return consts.NONCE_SIZE
+}
+
+func (s *gcmSIV) Overhead() int {
+	return consts.TAG_SIZE
+}
+
+// Seal encrypts and authenticates plaintext, authenticates
+// additionalData and appends the result to dst.
+//
+// To reuse plaintext's storage for the output, use plaintext[:0] as dst.
+func (s *gcmSIV) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != consts.NONCE_SIZE {
+		panic("aes256go: incorrect nonce length given to GCM-SIV")
+	}
+
+	// Both lengths are limited to 2^36 bytes.
+	if uint64(len(plaintext)) > 1<<36 || uint64(len(additionalData)) > 1<<36 {
+		panic("aes256go: message too large for GCM-SIV")
+	}
+
+	ret, out := sliceForAppend(dst, len(plaintext)+consts.TAG_SIZE)
+
+	if inexactOverlap(out, plaintext) {
+		panic("aes256go: invalid buffer overlap")
+	}
+
+	authKey, encCipher := s.deriveKeys(nonce)
+	defer clearDerivedKeys(authKey, encCipher)
+
+	tag := s.tag(authKey, encCipher, nonce, plaintext, additionalData)
+
+	// The tag has to be calculated before plaintext
+	// is overwritten when encrypting in place.
+	copy(out[len(plaintext):], tag)
+	gcmSIVCTR(encCipher, tag, out[:len(plaintext)], plaintext)
+
+	return ret
+}
+
+// Open decrypts and authenticates ciphertext, authenticates
+// additionalData and, if successful, appends the resulting
+// plaintext to dst.
+//
+// To reuse ciphertext's storage for the output, use ciphertext[:0] as dst.
+func (s *gcmSIV) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != consts.NONCE_SIZE {
+		panic("aes256go: incorrect nonce length given to GCM-SIV")
+	}
+
+	if len(ciphertext) < consts.TAG_SIZE {
+		return nil, errors.New("GCM-SIV authentication failed: cipherText too short")
+	}
+
+	if uint64(len(ciphertext)) > 1<<36+consts.TAG_SIZE || uint64(len(additionalData)) > 1<<36 {
+		return nil, errors.New("GCM-SIV authentication failed: message too large")
+	}
+
+	tag := make([]byte, consts.TAG_SIZE)
+	copy(tag, ciphertext[len(ciphertext)-consts.TAG_SIZE:])
+	ciphertext = ciphertext[:len(ciphertext)-consts.TAG_SIZE]
+
+	ret, out := sliceForAppend(dst, len(ciphertext))
+
+	if inexactOverlap(out, ciphertext) {
+		panic("aes256go: invalid buffer overlap")
+	}
+
+	authKey, encCipher := s.deriveKeys(nonce)
+	defer clearDerivedKeys(authKey, encCipher)
+
+	gcmSIVCTR(encCipher, tag, out, ciphertext)
+
+	// POLYVAL is calculated over the plainText, so it has to be
+	// decrypted first. It is wiped if the tag does not match.
+	if subtle.ConstantTimeCompare(tag, s.tag(authKey, encCipher, nonce, out, additionalData)) != 1 {
+		for i := range out {
+			out[i] = 0x00
+		}
+
+		return nil, errors.New("GCM-SIV authentication failed: Invalid authentication tag")
+	}
+
+	return ret, nil
+}
+
+// DeriveKeys returns the per-nonce message authentication key and
+// the cipher initialized with the message encryption key.
+//
+// https://www.rfc-editor.org/rfc/rfc8452#section-4
+func (s *gcmSIV) deriveKeys(nonce []byte) ([]byte, *AES256) {
+	n := 2 + len(s.cipher.Key)/8
+
+	blocks := make([]byte, n*consts.BLOCK_SIZE)
+	for i := 0; i < n; i++ {
+		binary.LittleEndian.PutUint32(blocks[i*consts.BLOCK_SIZE:], uint32(i))
+		copy(blocks[i*consts.BLOCK_SIZE+4:], nonce)
+	}
+
+	s.cipher.encryptBlocks(blocks, blocks)
+
+	// Only the first half of every block is used.
+	derived := make([]byte, n*8)
+	for i := 0; i < n; i++ {
+		copy(derived[i*8:], blocks[i*consts.BLOCK_SIZE:i*consts.BLOCK_SIZE+8])
+	}
+
+	encCipher, err := NewAES256FromKey(derived[consts.BLOCK_SIZE:], WithCore(s.cipher.core))
+
+	// The cipher keeps its own copy of the encryption key,
+	// so everything but the authentication key can be wiped.
+	for i := range blocks {
+		blocks[i] = 0x00
+	}
+
+	for i := range derived[consts.BLOCK_SIZE:] {
+		derived[consts.BLOCK_SIZE+i] = 0x00
+	}
+
+	if err != nil {
+		panic(err)
+	}
+
+	return derived[:consts.BLOCK_SIZE], encCipher
+}
+
+// ClearDerivedKeys wipes the per-nonce keys returned by deriveKeys
+// once the message has been processed.
+func clearDerivedKeys(authKey []byte, encCipher *AES256) {
+	for i := range authKey {
+		authKey[i] = 0x00
+	}
+
+	encCipher.ClearKey()
+}
+
+// Tag calculates the authentication tag, which also
+// serves as the initial counter block.
+//
+// https://www.rfc-editor.org/rfc/rfc8452#section-4
+func (s *gcmSIV) tag(authKey []byte, encCipher *AES256, nonce, plainText, authData []byte) []byte {
+	authLen := (len(authData) + consts.BLOCK_SIZE - 1) / consts.BLOCK_SIZE * consts.BLOCK_SIZE
+	plainLen := (len(plainText) + consts.BLOCK_SIZE - 1) / consts.BLOCK_SIZE * consts.BLOCK_SIZE
+
+	// POLYVAL input: A || 0^v || P || 0^u || [len(A)]64 || [len(P)]64,
+	// with both lengths given in bits, encoded in little-endian.
+	input := make([]byte, authLen+plainLen+consts.BLOCK_SIZE)
+	copy(input, authData)
+	copy(input[authLen:], plainText)
+	binary.LittleEndian.PutUint64(input[authLen+plainLen:], 8*uint64(len(authData)))
+	binary.LittleEndian.PutUint64(input[authLen+plainLen+8:], 8*uint64(len(plainText)))
+
+	sum := polyval(authKey, input)
+
+	for i, b := range nonce {
+		sum[i] ^= b
+	}
+
+	sum[consts.BLOCK_SIZE-1] &= 0x7f
+	encCipher.encryptBlocks(sum, sum)
+
+	return sum
+}
+
+// Polyval calculates POLYVAL(H, X_1, ..., X_s) using the GHASH
+// multiplication, as described in RFC 8452 Appendix A:
+//
+// POLYVAL(H, X_1, ..., X_n) = ByteReverse(GHASH(mulX_GHASH(ByteReverse(H)),
+// ByteReverse(X_1), ..., ByteReverse(X_n)))
+func polyval(h []byte, x []byte) []byte {
+	hg := reverseBlock(h)
+
+	// mulX_GHASH multiplies by x in the bit reflected GHASH field.
+	carry := hg[consts.BLOCK_SIZE-1] & 0x01
+	for i := consts.BLOCK_SIZE - 1; i > 0; i-- {
+		hg[i] = hg[i]>>1 | hg[i-1]<<7
+	}
+
+	hg[0] = hg[0]>>1 ^ 0xe1&-carry
+
+	sum := make([]byte, consts.BLOCK_SIZE)
+
+	for i := 0; i < len(x); i += consts.BLOCK_SIZE {
+		block := reverseBlock(x[i : i+consts.BLOCK_SIZE])
+		xorBlock(sum, block)
+		sum = g.GmulBlocks(sum, hg)
+	}
+
+	return reverseBlock(sum)
+}
+
+// ReverseBlock returns a copy of the block with the bytes reversed.
+func reverseBlock(block []byte) []byte {
+	out := make([]byte, consts.BLOCK_SIZE)
+
+	for i := range out {
+		out[i] = block[consts.BLOCK_SIZE-1-i]
+	}
+
+	return out
+}
+
+// GcmSIVCTR encrypts src into dst in the CTR variant of GCM-SIV.
+// The initial counter block is the tag with the most significant
+// bit set and the first 32 bits are incremented as a little-endian
+// integer, wrapping modulo 2^32.
+func gcmSIVCTR(c *AES256, tag []byte, dst, src []byte) {
+	ctrBlocks := make([]byte, ctrBatchSize*consts.BLOCK_SIZE)
+	keyStream := make([]byte, len(ctrBlocks))
+
+	ctrBlock := make([]byte, consts.BLOCK_SIZE)
+	copy(ctrBlock, tag)
+	ctrBlock[consts.BLOCK_SIZE-1] |= 0x80
+
+	ctr := binary.LittleEndian.Uint32(ctrBlock)
+
+	for i := 0; i < len(src); i += len(ctrBlocks) {
+		chunk := src[i:]
+		if len(chunk) > len(ctrBlocks) {
+			chunk = chunk[:len(ctrBlocks)]
+		}
+
+		n := (len(chunk) + consts.BLOCK_SIZE - 1) / consts.BLOCK_SIZE * consts.BLOCK_SIZE
+
+		for j := 0; j < n; j += consts.BLOCK_SIZE {
+			copy(ctrBlocks[j:], ctrBlock)
+			binary.LittleEndian.PutUint32(ctrBlocks[j:], ctr)
+			ctr++
+		}
+
+		c.encryptBlocks(keyStream[:n], ctrBlocks[:n])
+
+		for j, b := range chunk {
+			dst[i+j] = b ^ keyStream[j]
+		}
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+)
+
+// Test vectors from RFC 8452 Appendix C.
+var gcmSIVTests = []struct {
+	key        string
+	nonce      string
+	authData   string
+	plainText  string
+	cipherText string
+}{
+	// C.1 AEAD_AES_128_GCM_SIV
+	{
+		key:        "01000000000000000000000000000000",
+		nonce:      "030000000000000000000000",
+		cipherText: "dc20e2d83f25705bb49e439eca56de25",
+	},
+	{
+		key:        "01000000000000000000000000000000",
+		nonce:      "030000000000000000000000",
+		plainText:  "0100000000000000",
+		cipherText: "b5d839330ac7b786578782fff6013b815b287c22493a364c",
+	},
+	{
+		key:        "01000000000000000000000000000000",
+		nonce:      "030000000000000000000000",
+		plainText:  "010000000000000000000000",
+		cipherText: "7323ea61d05932260047d942a4978db357391a0bc4fdec8b0d106639",
+	},
+	// C.2 AEAD_AES_256_GCM_SIV
+	{
+		key:        "01000000000000000000000000000000 00000000000000000000000000000000",
+		nonce:      "030000000000000000000000",
+		cipherText: "07f5f4169bbf55a8400cd47ea6fd400f",
+	},
+	{
+		key:        "01000000000000000000000000000000 00000000000000000000000000000000",
+		nonce:      "030000000000000000000000",
+		plainText:  "0100000000000000",
+		cipherText: "c2ef328e5c71c83b843122130f7364b761e0b97427e3df28",
+	},
+	{
+		key:        "01000000000000000000000000000000 00000000000000000000000000000000",
+		nonce:      "030000000000000000000000",
+		plainText:  "010000000000000000000000",
+		cipherText: "9aab2aeb3faa0a34aea8e2b18ca50da9ae6559e48fd10f6e5c9ca17e",
+	},
+	{
+		key:        "01000000000000000000000000000000 00000000000000000000000000000000",
+		nonce:      "030000000000000000000000",
+		plainText:  "01000000000000000000000000000000",
+		cipherText: "85a01b63025ba19b7fd3ddfc033b3e76 c9eac6fa700942702e90862383c6c366",
+	},
+	{
+		key:        "01000000000000000000000000000000 00000000000000000000000000000000",
+		nonce:      "030000000000000000000000",
+		authData:   "01",
+		plainText:  "0200000000000000",
+		cipherText: "1de22967237a813291213f267e3b452f02d01ae33e4ec854",
+	},
+	// C.3 Counter wrap tests
+	{
+		key:        "00000000000000000000000000000000 00000000000000000000000000000000",
+		nonce:      "000000000000000000000000",
+		plainText:  "00000000000000000000000000000000 4db923dc793ee6497c76dcc03a98e108",
+		cipherText: "f3f80f2cf0cb2dd9c5984fcda908456c c537703b5ba70324a6793a7bf218d3ea ffffffff000000000000000000000000",
+	},
+}
+
+func TestGCMSIVVectors(t *testing.T) {
+	for i, test := range gcmSIVTests {
+		a, err := NewAES256FromKey(decodeHex(test.key))
+		if err != nil {
+			panic(err)
+		}
+
+		aead, err := a.NewGCMSIV()
+		if err != nil {
+			panic(err)
+		}
+
+		nonce := decodeHex(test.nonce)
+		expected := decodeHex(test.cipherText)
+
+		actual := aead.Seal(nil, nonce, decodeHex(test.plainText), decodeHex(test.authData))
+		if !bytes.Equal(actual, expected) {
+			t.Fatalf("FAILED: GCM-SIV vector %d seal mismatch: %x", i, actual)
+		}
+
+		plainText, err := aead.Open(nil, nonce, expected, decodeHex(test.authData))
+		if err != nil || !bytes.Equal(plainText, decodeHex(test.plainText)) {
+			t.Fatalf("FAILED: GCM-SIV vector %d open failed", i)
+		}
+	}
+}
+
+func TestPOLYVAL(t *testing.T) {
+	// RFC 8452 Appendix A.
+	h := decodeHex("25629347589242761d31f826ba4b757b")
+	x := decodeHex("4f4f95668c83dfb6401762bb2d01a262 d1a24ddd2721d006bbe45f20d3c9f362")
+
+	if !bytes.Equal(polyval(h, x), decodeHex("f7a3b47b846119fae5b7866cf5e5b77e")) {
+		t.Fatalf("FAILED: POLYVAL mismatch")
+	}
+}
+
+func TestGCMSIV(t *testing.T) {
+	a, err := NewAES256([]byte("gcm-siv key"))
+	if err != nil {
+		panic(err)
+	}
+
+	plainText := []byte("Some plainText that spans several blocks of the cipher")
+	authData := []byte("authData")
+
+	cipherText, err := a.EncryptGCMSIV(plainText, authData)
+	if err != nil {
+		panic(err)
+	}
+
+	actual, err := a.DecryptGCMSIV(cipherText, authData)
+	if err != nil || !bytes.Equal(actual, plainText) {
+		t.Fatalf("FAILED: GCM-SIV round trip failed")
+	}
+
+	for _, i := range []int{0, consts.NONCE_SIZE, len(cipherText) - 1} {
+		tampered := append([]byte{}, cipherText...)
+		tampered[i] ^= 0x01
+
+		if _, err := a.DecryptGCMSIV(tampered, authData); err == nil {
+			t.Fatalf("FAILED: tampered byte %d accepted", i)
+		}
+	}
+
+	if _, err := a.DecryptGCMSIV(cipherText, []byte("other authData")); err == nil {
+		t.Fatalf("FAILED: wrong authData accepted")
+	}
+
+	if _, err := a.DecryptGCMSIV(cipherText[:consts.NONCE_SIZE+consts.TAG_SIZE-1], authData); err == nil {
+		t.Fatalf("FAILED: short cipherText accepted")
+	}
+
+	// In place encryption and decryption.
+	aead, err := a.NewGCMSIV()
+	if err != nil {
+		panic(err)
+	}
+
+	nonce := make([]byte, consts.NONCE_SIZE)
+	buf := append(make([]byte, 0, len(plainText)+consts.TAG_SIZE), plainText...)
+
+	sealed := aead.Seal(buf[:0], nonce, buf, authData)
+	if !bytes.Equal(sealed, aead.Seal(nil, nonce, plainText, authData)) {
+		t.Fatalf("FAILED: in place seal mismatch")
+	}
+
+	opened, err := aead.Open(sealed[:0], nonce, sealed, authData)
+	if err != nil || !bytes.Equal(opened, plainText) {
+		t.Fatalf("FAILED: in place open failed")
+	}
+
+	b, err := NewAES256FromKey(make([]byte, consts.KEY_SIZE_192))
+	if err != nil {
+		panic(err)
+	}
+
+	if _, err := b.NewGCMSIV(); err == nil {
+		t.Fatalf("FAILED: 192 bit key accepted")
+	}
+}
@@ -0,0 +1,340 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+)
+
+// Size of the random salt written at the beginning of a GCM stream.
+// Every stream is sealed with its own key derived from the salt.
+const streamSaltSize = consts.KEY_SIZE
+
+// Size of the random nonce prefix following the salt. The remaining
+// 5 bytes of the GCM nonce are the segment counter and the last
+// segment flag.
+const streamPrefixSize = consts.NONCE_SIZE - 5
+
+// Size of the stream header: salt || nonce prefix.
+const streamHeaderSize = streamSaltSize + streamPrefixSize
+
+// GcmStreamWriter seals the data written to it segment by segment.
+type gcmStreamWriter struct {
+	w        io.Writer
+	aead     cipher.AEAD
+	authData []byte
+	prefix   []byte
+	counter  uint32
+	buf      []byte
+	out      []byte
+	err      error
+}
+
+// GcmStreamReader opens the segments read from the underlying reader.
+type gcmStreamReader struct {
+	r        io.Reader
+	aead     cipher.AEAD
+	authData []byte
+	prefix   []byte
+	counter  uint32
+	buf      []byte
+	carried  int
+	plain    []byte
+	pos      int
+	err      error
+}
+
+// NewGCMStreamWriter returns a writer encrypting and authenticating
+// the data written to it into w, using the STREAM construction over GCM.
+//
+// The plainText is split into segments of segmentSize bytes, each one
+// sealed with its own tag and a nonce derived from a random prefix,
+// the segment number and a flag marking the last segment. This way
+// truncation, reordering and extension of the segments are detected.
+// The authData is authenticated with every segment.
+//
+// Like Tink's AES-GCM-HKDF streaming AEAD, every stream is sealed with
+// its own key, derived with HKDF-SHA256 from the key, a random 32 byte
+// salt and the authData. A 7 byte nonce prefix alone would repeat
+// after a few million streams under one key, the salt removes that
+// limit. The salt and prefix are written at the beginning of w.
+//
+// Close has to be called to write the last segment, it does not
+// close w.
+//
+// https://eprint.iacr.org/2015/189.pdf
+func (a *AES256) NewGCMStreamWriter(w io.Writer, segmentSize int, authData []byte) (io.WriteCloser, error) {
+	if segmentSize < 1 {
+		return nil, errors.New("invalid segment size")
+	}
+
+	header := make([]byte, streamHeaderSize)
+	if err := readRandom(a.rand, header, "nonce"); err != nil {
+		return nil, err
+	}
+
+	aead, err := a.newStreamAEAD(header[:streamSaltSize], authData)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &gcmStreamWriter{
+		w:        w,
+		aead:     aead,
+		authData: authData,
+		prefix:   header[streamSaltSize:],
+		buf:      make([]byte, 0, segmentSize),
+		out:      make([]byte, 0, segmentSize+aead.Overhead()),
+	}, nil
+}
+
+// NewGCMStreamReader returns a reader decrypting the output of
+// NewGCMStreamWriter read from r. The segmentSize and authData
+// have to match the ones used for encryption.
+//
+// Every segment is authenticated before it is released, and an
+// error is returned if the stream was truncated or extended.
+// Data read before an error comes from authentic segments only,
+// but the message as a whole is only complete once io.EOF is
+// returned.
+func (a *AES256) NewGCMStreamReader(r io.Reader, segmentSize int, authData []byte) (io.Reader, error) {
+	if segmentSize < 1 {
+		return nil, errors.New("invalid segment size")
+	}
+
+	header := make([]byte, streamHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, errors.New("cipherText too short: missing nonce")
+	}
+
+	aead, err := a.newStreamAEAD(header[:streamSaltSize], authData)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &gcmStreamReader{
+		r:        r,
+		aead:     aead,
+		authData: authData,
+		prefix:   header[streamSaltSize:],
+		// One more byte than a sealed segment is read
+		// to tell whether the segment is the last one.
+		buf:   make([]byte, segmentSize+aead.Overhead()+1),
+		plain: make([]byte, 0, segmentSize),
+	}, nil
+}
+
+// NewStreamAEAD returns GCM under the key of the stream with the
+// given salt: HKDF-SHA256(key, salt, authData), as long as the key.
+func (a *AES256) newStreamAEAD(salt []byte, authData []byte) (cipher.AEAD, error) {
+	streamKey := hkdfSHA256(a.Key, salt, authData, len(a.Key))
+
+	c, err := NewAES256FromKey(streamKey, WithCore(a.core))
+
+	for i := range streamKey {
+		streamKey[i] = 0x00
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return c.NewGCM()
+}
+
+// HkdfSHA256 derives length bytes from secret as described in RFC 5869.
+//
+// https://www.rfc-editor.org/rfc/rfc5869
+func hkdfSHA256(secret, salt, info []byte, length int) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	prk := extract.Sum(nil)
+
+	expand := hmac.New(sha256.New, prk)
+	var out, t []byte
+
+	for i := byte(1); len(out) < length; i++ {
+		expand.Reset()
+		expand.Write(t)
+		expand.Write(info)
+		expand.Write([]byte{i})
+		t = expand.Sum(nil)
+		out = append(out, t...)
+	}
+
+	for i := range prk {
+		prk[i] = 0x00
+	}
+
+	for i := length; i < len(out); i++ {
+		out[i] = 0x00
+	}
+
+	return out[:length]
+}
+
+// StreamNonce returns the GCM nonce of the given segment:
+// prefix || [counter]32 || last segment flag.
+func streamNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, consts.NONCE_SIZE)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[streamPrefixSize:], counter)
+
+	if last {
+		nonce[consts.NONCE_SIZE-1] = 0x01
+	}
+
+	return nonce
+}
+
+func (s *gcmStreamWriter) Write(p []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	written := 0
+
+	for len(p) > 0 {
+		// A full segment is only sealed once more data arrives,
+		// since the last one has to be sealed with the flag set.
+		if len(s.buf) == cap(s.buf) {
+			if err := s.seal(false); err != nil {
+				return written, err
+			}
+		}
+
+		n := copy(s.buf[len(s.buf):cap(s.buf)], p)
+		s.buf = s.buf[:len(s.buf)+n]
+		p = p[n:]
+		written += n
+	}
+
+	return written, nil
+}
+
+// Close seals the last segment. The writer can't be used afterwards.
+func (s *gcmStreamWriter) Close() error {
+	if s.err != nil {
+		return s.err
+	}
+
+	if err := s.seal(true); err != nil {
+		return err
+	}
+
+	s.err = errors.New("write to closed GCM stream")
+	return nil
+}
+
+// Seal writes the buffered segment to the underlying writer.
+func (s *gcmStreamWriter) seal(last bool) error {
+	if !last && s.counter == math.MaxUint32 {
+		s.err = errors.New("GCM stream too long: segment counter exhausted")
+		return s.err
+	}
+
+	s.out = s.aead.Seal(s.out[:0], streamNonce(s.prefix, s.counter, last), s.buf, s.authData)
+
+	if _, err := s.w.Write(s.out); err != nil {
+		s.err = err
+		return err
+	}
+
+	s.buf = s.buf[:0]
+	s.counter++
+
+	return nil
+}
+
+func (s *gcmStreamReader) Read(p []byte) (int, error) {
+	for s.pos == len(s.plain) {
+		if s.err != nil {
+			return 0, s.err
+		}
+
+		s.err = s.open()
+	}
+
+	n := copy(p, s.plain[s.pos:])
+	s.pos += n
+
+	return n, nil
+}
+
+// Open reads and authenticates the next segment. It returns
+// io.EOF after the last segment has been opened.
+func (s *gcmStreamReader) open() error {
+	n, err := io.ReadFull(s.r, s.buf[s.carried:])
+	n += s.carried
+
+	var last bool
+
+	switch err {
+	case nil:
+		last = false
+		n--
+	case io.EOF, io.ErrUnexpectedEOF:
+		last = true
+	default:
+		return err
+	}
+
+	if n < s.aead.Overhead() {
+		return errors.New("GCM stream authentication failed: truncated segment")
+	}
+
+	plain, err := s.aead.Open(s.plain[:0], streamNonce(s.prefix, s.counter, last), s.buf[:n], s.authData)
+
+	if err != nil {
+		return errors.New("GCM stream authentication failed: invalid segment")
+	}
+
+	s.plain = plain
+	s.pos = 0
+
+	if last {
+		return io.EOF
+	}
+
+	if s.counter == math.MaxUint32 {
+		return errors.New("GCM stream too long: segment counter exhausted")
+	}
+
+	s.counter++
+	s.buf[0] = s.buf[len(s.buf)-1]
+	s.carried = 1
+
+	return nil
+}
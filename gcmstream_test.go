@@ -0,0 +1,252 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+)
+
+func sealGCMStream(a *AES256, plainText []byte, segmentSize int, chunk int) []byte {
+	var buf bytes.Buffer
+
+	w, err := a.NewGCMStreamWriter(&buf, segmentSize, []byte("header"))
+	if err != nil {
+		panic(err)
+	}
+
+	for i := 0; i < len(plainText); i += chunk {
+		j := i + chunk
+		if j > len(plainText) {
+			j = len(plainText)
+		}
+
+		if _, err := w.Write(plainText[i:j]); err != nil {
+			panic(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		panic(err)
+	}
+
+	return buf.Bytes()
+}
+
+func openGCMStream(a *AES256, cipherText []byte, segmentSize int) ([]byte, error) {
+	r, err := a.NewGCMStreamReader(bytes.NewReader(cipherText), segmentSize, []byte("header"))
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(r)
+}
+
+func TestGCMStreamRoundTrip(t *testing.T) {
+	a, err := NewAES256FromKey(bytes.Repeat([]byte{0x33}, consts.KEY_SIZE))
+	if err != nil {
+		panic(err)
+	}
+
+	plainText := bytes.Repeat([]byte("segmented AEAD "), 50)
+	segmentSize := 64
+
+	for _, size := range []int{0, 1, 63, 64, 65, 128, 129, len(plainText)} {
+		for _, chunk := range []int{1, 7, 64, 1000} {
+			cipherText := sealGCMStream(a, plainText[:size], segmentSize, chunk)
+
+			segments := (size + segmentSize - 1) / segmentSize
+			if segments == 0 {
+				segments = 1
+			}
+
+			if len(cipherText) != streamHeaderSize+size+segments*consts.TAG_SIZE {
+				t.Fatalf("FAILED: unexpected GCM stream size for %d bytes", size)
+			}
+
+			decrypted, err := openGCMStream(a, cipherText, segmentSize)
+			if err != nil || !bytes.Equal(decrypted, plainText[:size]) {
+				t.Fatalf("FAILED: GCM stream round trip failed for %d bytes in chunks of %d: %v", size, chunk, err)
+			}
+		}
+	}
+}
+
+func TestGCMStreamSegmentsAreGCM(t *testing.T) {
+	a, err := NewAES256FromKey(make([]byte, consts.KEY_SIZE))
+	if err != nil {
+		panic(err)
+	}
+
+	plainText := []byte("two segments")
+	cipherText := sealGCMStream(a, plainText, 8, 100)
+	salt := cipherText[:streamSaltSize]
+	prefix := cipherText[streamSaltSize:streamHeaderSize]
+
+	streamCipher, err := NewAES256FromKey(hkdfSHA256(a.Key, salt, []byte("header"), consts.KEY_SIZE))
+	if err != nil {
+		panic(err)
+	}
+
+	aead, err := streamCipher.NewGCM()
+	if err != nil {
+		panic(err)
+	}
+
+	first := aead.Seal(nil, streamNonce(prefix, 0, false), plainText[:8], []byte("header"))
+	last := aead.Seal(nil, streamNonce(prefix, 1, true), plainText[8:], []byte("header"))
+
+	if !bytes.Equal(cipherText[streamHeaderSize:], append(first, last...)) {
+		t.Fatalf("FAILED: GCM stream segments do not match GCM")
+	}
+}
+
+func TestGCMStreamKeys(t *testing.T) {
+	k := bytes.Repeat([]byte{0x44}, consts.KEY_SIZE)
+	prefix := bytes.Repeat([]byte{0x55}, streamPrefixSize)
+	plainText := []byte("same nonce prefix")
+
+	// Two streams with the same nonce prefix but different salts
+	// must not share a key, so their segments have to differ.
+	var sealed [][]byte
+
+	for _, salt := range [][]byte{make([]byte, streamSaltSize), bytes.Repeat([]byte{0x01}, streamSaltSize)} {
+		a, err := NewAES256FromKey(k, WithRand(bytes.NewReader(append(append([]byte{}, salt...), prefix...))))
+		if err != nil {
+			panic(err)
+		}
+
+		cipherText := sealGCMStream(a, plainText, 64, 64)
+
+		decrypted, err := openGCMStream(a, cipherText, 64)
+		if err != nil || !bytes.Equal(decrypted, plainText) {
+			t.Fatalf("FAILED: GCM stream round trip failed")
+		}
+
+		sealed = append(sealed, cipherText[streamHeaderSize:])
+	}
+
+	if bytes.Equal(sealed[0][:len(plainText)], sealed[1][:len(plainText)]) {
+		t.Fatalf("FAILED: GCM streams with different salts share a key")
+	}
+}
+
+// Test case 1 from RFC 5869 Appendix A.
+func TestHKDFSHA256(t *testing.T) {
+	okm := hkdfSHA256(bytes.Repeat([]byte{0x0b}, 22), decodeHex("000102030405060708090a0b0c"), decodeHex("f0f1f2f3f4f5f6f7f8f9"), 42)
+	expected := decodeHex("3cb25f25faacd57a90434f64d0362f2a 2d2d0a90cf1a5a4c5db02d56ecc4c5bf 34007208d5b887185865")
+
+	if !bytes.Equal(okm, expected) {
+		t.Fatalf("FAILED: HKDF-SHA256 output mismatch")
+	}
+}
+
+func TestGCMStreamTampering(t *testing.T) {
+	a, err := NewAES256FromKey(make([]byte, consts.KEY_SIZE))
+	if err != nil {
+		panic(err)
+	}
+
+	segmentSize := 16
+	sealed := segmentSize + consts.TAG_SIZE
+	plainText := bytes.Repeat([]byte("0123456789abcdef"), 4)
+	cipherText := sealGCMStream(a, plainText, segmentSize, 5)
+
+	segment := func(i int) []byte {
+		start := streamHeaderSize + i*sealed
+		return cipherText[start : start+sealed]
+	}
+
+	join := func(parts ...[]byte) []byte {
+		var out []byte
+		for _, p := range parts {
+			out = append(out, p...)
+		}
+
+		return out
+	}
+
+	prefix := cipherText[:streamHeaderSize]
+	otherStream := sealGCMStream(a, plainText, segmentSize, 5)
+
+	for name, forged := range map[string][]byte{
+		"truncated at a segment boundary": join(prefix, segment(0), segment(1)),
+		"truncated inside a segment":      cipherText[:len(cipherText)-3],
+		"reordered":                       join(prefix, segment(1), segment(0), segment(2), segment(3)),
+		"extended with a segment":         join(cipherText, segment(3)),
+		"extended with a byte":            join(cipherText, []byte{0x00}),
+		"last segment dropped":            join(prefix, segment(0), segment(1), segment(2)),
+		"segment from another stream":     join(prefix, segment(0), otherStream[streamHeaderSize+sealed:streamHeaderSize+2*sealed], segment(2), segment(3)),
+		"header only":                     prefix,
+	} {
+		if _, err := openGCMStream(a, forged, segmentSize); err == nil {
+			t.Fatalf("FAILED: GCM stream accepted a stream %s", name)
+		}
+	}
+
+	r, err := a.NewGCMStreamReader(bytes.NewReader(cipherText), segmentSize, []byte("other header"))
+	if err != nil {
+		panic(err)
+	}
+
+	if _, err := io.ReadAll(r); err == nil {
+		t.Fatalf("FAILED: GCM stream accepted wrong additional data")
+	}
+
+	if _, err := openGCMStream(a, cipherText, segmentSize+1); err == nil {
+		t.Fatalf("FAILED: GCM stream accepted a wrong segment size")
+	}
+
+	if _, err := a.NewGCMStreamWriter(io.Discard, 0, nil); err == nil {
+		t.Fatalf("FAILED: zero segment size accepted")
+	}
+
+	if _, err := a.NewGCMStreamReader(bytes.NewReader(prefix[:3]), segmentSize, nil); err == nil {
+		t.Fatalf("FAILED: GCM stream reader accepted a truncated header")
+	}
+}
+
+func TestGCMStreamClosed(t *testing.T) {
+	a, err := NewAES256FromKey(make([]byte, consts.KEY_SIZE))
+	if err != nil {
+		panic(err)
+	}
+
+	w, err := a.NewGCMStreamWriter(io.Discard, 16, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := w.Close(); err != nil {
+		panic(err)
+	}
+
+	if _, err := w.Write([]byte("too late")); err == nil {
+		t.Fatalf("FAILED: write after Close accepted")
+	}
+
+	if err := w.Close(); err == nil {
+		t.Fatalf("FAILED: second Close accepted")
+	}
+}
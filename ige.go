@@ -0,0 +1,110 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"errors"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+	g "github.com/wedkarz02/aes256go/src/galois"
+)
+
+// Size of the IGE initialization vector, which
+// consists of two blocks.
+const igeIVSize = 2 * consts.BLOCK_SIZE
+
+// Data encryption using IGE mode. The IV is 32 bytes long: the first
+// block stands for the previous cipherText block, the second one for
+// the previous plainText block, as in OpenSSL.
+//
+// The IV is not prepended to the cipherText and no padding is applied,
+// so the plainText has to be a multiple of the block size.
+//
+// https://www.links.org/files/openssl-ige.pdf
+func (a *AES256) EncryptIGE(iv []byte, plainText []byte) ([]byte, error) {
+	if len(iv) != igeIVSize {
+		return nil, errors.New("invalid iv size")
+	}
+
+	if len(plainText)%consts.BLOCK_SIZE != 0 {
+		return nil, errors.New("plainText size not matching the block size")
+	}
+
+	cipherText := make([]byte, 0, len(plainText))
+
+	prevCipher := iv[:consts.BLOCK_SIZE]
+	prevPlain := iv[consts.BLOCK_SIZE:]
+
+	// c_i = E(p_i ^ c_{i-1}) ^ p_{i-1}
+	for i := 0; i < len(plainText); i += consts.BLOCK_SIZE {
+		plainBlock := plainText[i : i+consts.BLOCK_SIZE]
+		encBlock, err := a.EncryptBlock(g.GxorBlocks(plainBlock, prevCipher))
+
+		if err != nil {
+			return nil, err
+		}
+
+		cipherBlock := g.GxorBlocks(encBlock, prevPlain)
+		cipherText = append(cipherText, cipherBlock...)
+
+		prevCipher = cipherBlock
+		prevPlain = plainBlock
+	}
+
+	return cipherText, nil
+}
+
+// Data decryption using IGE mode. The IV has to be the same
+// as the one used for encryption.
+//
+// https://www.links.org/files/openssl-ige.pdf
+func (a *AES256) DecryptIGE(iv []byte, cipherText []byte) ([]byte, error) {
+	if len(iv) != igeIVSize {
+		return nil, errors.New("invalid iv size")
+	}
+
+	if len(cipherText)%consts.BLOCK_SIZE != 0 {
+		return nil, errors.New("cipherText size not matching the block size")
+	}
+
+	plainText := make([]byte, 0, len(cipherText))
+
+	prevCipher := iv[:consts.BLOCK_SIZE]
+	prevPlain := iv[consts.BLOCK_SIZE:]
+
+	// p_i = D(c_i ^ p_{i-1}) ^ c_{i-1}
+	for i := 0; i < len(cipherText); i += consts.BLOCK_SIZE {
+		cipherBlock := cipherText[i : i+consts.BLOCK_SIZE]
+		decBlock, err := a.DecryptBlock(g.GxorBlocks(cipherBlock, prevPlain))
+
+		if err != nil {
+			return nil, err
+		}
+
+		plainBlock := g.GxorBlocks(decBlock, prevCipher)
+		plainText = append(plainText, plainBlock...)
+
+		prevCipher = cipherBlock
+		prevPlain = plainBlock
+	}
+
+	return plainText, nil
+}
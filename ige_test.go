@@ -0,0 +1,187 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+)
+
+// Test vectors from OpenSSL test/igetest.c. They use 128 bit keys,
+// there are no published AES-256 IGE vectors.
+var igeTests = []struct {
+	key        string
+	iv         string
+	plainText  string
+	cipherText string
+}{
+	{
+		key:        "000102030405060708090a0b0c0d0e0f",
+		iv:         "000102030405060708090a0b0c0d0e0f 101112131415161718191a1b1c1d1e1f",
+		plainText:  "00000000000000000000000000000000 00000000000000000000000000000000",
+		cipherText: "1a8519a6557be652e9da8e43da4ef445 3cf456b4ca488aa383c79c98b34797cb",
+	},
+	{
+		key:        "5468697320697320616e20696d706c65",
+		iv:         "6d656e746174696f6e206f6620494745 206d6f646520666f72204f70656e5353",
+		plainText:  "99706487a1cde613bc6de0b6f24b1c7a a448c8b9c3403e3467a8cad89340f53b",
+		cipherText: "4c2e204c6574277320686f70652042656e20676f74206974207269676874210a",
+	},
+}
+
+// An AES-256 known answer derived from the CBC-AES256 example in NIST
+// SP 800-38A F.2.5. IGE passes the block cipher P_i ^ C_i-1 like CBC
+// does, so with the example's IV followed by zeros as the IGE IV, these
+// plainText blocks make it encrypt the same blocks as the example. Each
+// cipherText block is the published one XORed with the previous IGE
+// plainText block.
+const (
+	igePlainTextAES256 = "6bc1bee22e409f96e93d7e117393172a ae2d8a571e03ac9c9eb76fac45af8e51" +
+		"5b09a2a48d1c7b870cc6bf08699945c5 58b2ae12c14c378b339c2ed7a3c3b941"
+	igeCipherTextAES256 = "f58c4c04d6e5f1ba779eabfb5f7bfbd6 f73df074509b1f1b8ea2096ab5e33b57" +
+		"97dfb93eb7da16533b878dcf418c9a30 e9e2a7464e87927bd6aaa60fe5f3d8de"
+)
+
+func TestIGEVectors(t *testing.T) {
+	for i, test := range igeTests {
+		a, err := NewAES256FromKey(decodeHex(test.key))
+		if err != nil {
+			panic(err)
+		}
+
+		iv := decodeHex(test.iv)
+
+		actual, err := a.EncryptIGE(iv, decodeHex(test.plainText))
+		if err != nil {
+			panic(err)
+		}
+
+		if !bytes.Equal(actual, decodeHex(test.cipherText)) {
+			t.Fatalf("FAILED: IGE vector %d encryption failed", i+1)
+		}
+
+		actual, err = a.DecryptIGE(iv, actual)
+		if err != nil {
+			panic(err)
+		}
+
+		if !bytes.Equal(actual, decodeHex(test.plainText)) {
+			t.Fatalf("FAILED: IGE vector %d decryption failed", i+1)
+		}
+	}
+}
+
+func TestIGEVectorsAES256(t *testing.T) {
+	a, err := NewAES256FromKey(decodeHex(sp80038aKey))
+	if err != nil {
+		panic(err)
+	}
+
+	iv := append(decodeHex(sp80038aIV), make([]byte, consts.BLOCK_SIZE)...)
+
+	actual, err := a.EncryptIGE(iv, decodeHex(igePlainTextAES256))
+	if err != nil {
+		panic(err)
+	}
+
+	if !bytes.Equal(actual, decodeHex(igeCipherTextAES256)) {
+		t.Fatalf("FAILED: IGE-AES256 encryption failed")
+	}
+
+	actual, err = a.DecryptIGE(iv, actual)
+	if err != nil {
+		panic(err)
+	}
+
+	if !bytes.Equal(actual, decodeHex(igePlainTextAES256)) {
+		t.Fatalf("FAILED: IGE-AES256 decryption failed")
+	}
+}
+
+func TestIGE(t *testing.T) {
+	a, err := NewAES256([]byte("ige key"))
+	if err != nil {
+		panic(err)
+	}
+
+	iv := make([]byte, igeIVSize)
+	for i := range iv {
+		iv[i] = byte(i)
+	}
+
+	data := bytes.Repeat([]byte("infinite garble "), 16)
+
+	for _, blocks := range []int{0, 1, 2, 3, 16} {
+		plainText := data[:blocks*consts.BLOCK_SIZE]
+
+		cipherText, err := a.EncryptIGE(iv, plainText)
+		if err != nil {
+			panic(err)
+		}
+
+		actual, err := a.DecryptIGE(iv, cipherText)
+		if err != nil {
+			panic(err)
+		}
+
+		if !bytes.Equal(actual, plainText) {
+			t.Fatalf("FAILED: IGE-AES256 round trip failed for %d blocks", blocks)
+		}
+	}
+
+	// A changed cipherText block garbles all of the following plainText blocks.
+	plainText := make([]byte, 4*consts.BLOCK_SIZE)
+	cipherText, err := a.EncryptIGE(iv, plainText)
+	if err != nil {
+		panic(err)
+	}
+
+	cipherText[consts.BLOCK_SIZE] ^= 0x01
+
+	actual, err := a.DecryptIGE(iv, cipherText)
+	if err != nil {
+		panic(err)
+	}
+
+	if !bytes.Equal(actual[:consts.BLOCK_SIZE], plainText[:consts.BLOCK_SIZE]) {
+		t.Fatalf("FAILED: IGE error propagated backwards")
+	}
+
+	for i := consts.BLOCK_SIZE; i < len(actual); i += consts.BLOCK_SIZE {
+		if bytes.Equal(actual[i:i+consts.BLOCK_SIZE], plainText[i:i+consts.BLOCK_SIZE]) {
+			t.Fatalf("FAILED: IGE error did not propagate to block %d", i/consts.BLOCK_SIZE)
+		}
+	}
+
+	if _, err := a.EncryptIGE(iv[:consts.BLOCK_SIZE], plainText); err == nil {
+		t.Fatalf("FAILED: 16 byte iv accepted")
+	}
+
+	if _, err := a.EncryptIGE(iv, plainText[1:]); err == nil {
+		t.Fatalf("FAILED: partial block encrypted")
+	}
+
+	if _, err := a.DecryptIGE(iv, cipherText[1:]); err == nil {
+		t.Fatalf("FAILED: partial block decrypted")
+	}
+}
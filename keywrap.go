@@ -0,0 +1,234 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+)
+
+// Size of the key wrap semiblock.
+const semiblockSize = consts.BLOCK_SIZE / 2
+
+// Default initial value for KW.
+//
+// https://www.rfc-editor.org/rfc/rfc3394#section-2.2.3.1
+var kwIV = []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+
+// Alternative initial value prefix for KWP, followed
+// by the 32 bit length of the key data.
+//
+// https://www.rfc-editor.org/rfc/rfc5649#section-3
+var kwpIV = []byte{0xa6, 0x59, 0x59, 0xa6}
+
+// WrapKey encrypts the key data with the AES Key Wrap algorithm (KW).
+// The key data has to be a multiple of 8 bytes and at least 16 bytes long.
+// The wrapped key is 8 bytes longer than the key data.
+//
+// https://www.rfc-editor.org/rfc/rfc3394
+func (a *AES256) WrapKey(keyData []byte) ([]byte, error) {
+	if len(keyData) < 2*semiblockSize || len(keyData)%semiblockSize != 0 {
+		return nil, errors.New("invalid key data size")
+	}
+
+	return a.wrap(kwIV, keyData)
+}
+
+// UnwrapKey decrypts the key wrapped with the AES Key Wrap algorithm (KW)
+// and verifies its integrity.
+//
+// https://www.rfc-editor.org/rfc/rfc3394
+func (a *AES256) UnwrapKey(wrappedKey []byte) ([]byte, error) {
+	if len(wrappedKey) < 3*semiblockSize || len(wrappedKey)%semiblockSize != 0 {
+		return nil, errors.New("invalid wrapped key size")
+	}
+
+	iv, keyData, err := a.unwrap(wrappedKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare(iv, kwIV) != 1 {
+		for i := range keyData {
+			keyData[i] = 0x00
+		}
+
+		return nil, errors.New("key unwrap failed: integrity check failed")
+	}
+
+	return keyData, nil
+}
+
+// WrapKeyPadded encrypts the key data with the AES Key Wrap with Padding
+// algorithm (KWP). The key data can be of any non-zero length and is padded
+// with zeros to a multiple of 8 bytes.
+//
+// https://www.rfc-editor.org/rfc/rfc5649
+func (a *AES256) WrapKeyPadded(keyData []byte) ([]byte, error) {
+	if len(keyData) == 0 || uint64(len(keyData)) > 1<<32-1 {
+		return nil, errors.New("invalid key data size")
+	}
+
+	iv := make([]byte, semiblockSize)
+	copy(iv, kwpIV)
+	binary.BigEndian.PutUint32(iv[len(kwpIV):], uint32(len(keyData)))
+
+	padded := make([]byte, (len(keyData)+semiblockSize-1)/semiblockSize*semiblockSize)
+	copy(padded, keyData)
+
+	// A single semiblock is encrypted with the IV as one AES block.
+	if len(padded) == semiblockSize {
+		return a.EncryptBlock(append(iv, padded...))
+	}
+
+	return a.wrap(iv, padded)
+}
+
+// UnwrapKeyPadded decrypts the key wrapped with the AES Key Wrap with
+// Padding algorithm (KWP) and verifies its integrity, length and padding.
+//
+// https://www.rfc-editor.org/rfc/rfc5649
+func (a *AES256) UnwrapKeyPadded(wrappedKey []byte) ([]byte, error) {
+	if len(wrappedKey) < 2*semiblockSize || len(wrappedKey)%semiblockSize != 0 {
+		return nil, errors.New("invalid wrapped key size")
+	}
+
+	var iv, padded []byte
+
+	if len(wrappedKey) == 2*semiblockSize {
+		block, err := a.DecryptBlock(wrappedKey)
+
+		if err != nil {
+			return nil, err
+		}
+
+		iv, padded = block[:semiblockSize], block[semiblockSize:]
+	} else {
+		var err error
+		iv, padded, err = a.unwrap(wrappedKey)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The length has to fit in the last semiblock and all
+	// padding bytes have to be zero. All checks are done
+	// before returning to avoid leaking which one failed.
+	mli := binary.BigEndian.Uint32(iv[len(kwpIV):])
+	n := int(mli & 0x7fffffff)
+
+	ok := subtle.ConstantTimeCompare(iv[:len(kwpIV)], kwpIV)
+	ok &= subtle.ConstantTimeEq(int32(mli>>31), 0)
+	ok &= subtle.ConstantTimeLessOrEq(len(padded)-semiblockSize+1, n)
+	ok &= subtle.ConstantTimeLessOrEq(n, len(padded))
+
+	var pad byte
+	for i := len(padded) - semiblockSize; i < len(padded); i++ {
+		// Bytes before n belong to the key data.
+		mask := byte(subtle.ConstantTimeLessOrEq(n, i) * 0xff)
+		pad |= padded[i] & mask
+	}
+
+	ok &= subtle.ConstantTimeByteEq(pad, 0x00)
+
+	if ok != 1 {
+		for i := range padded {
+			padded[i] = 0x00
+		}
+
+		return nil, errors.New("key unwrap failed: integrity check failed")
+	}
+
+	return padded[:n], nil
+}
+
+// Wrap is the wrapping process W shared by KW and KWP.
+//
+// https://www.rfc-editor.org/rfc/rfc3394#section-2.2.1
+func (a *AES256) wrap(iv []byte, keyData []byte) ([]byte, error) {
+	n := len(keyData) / semiblockSize
+
+	out := make([]byte, semiblockSize+len(keyData))
+	copy(out, iv)
+	copy(out[semiblockSize:], keyData)
+
+	block := make([]byte, consts.BLOCK_SIZE)
+
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			r := out[i*semiblockSize : (i+1)*semiblockSize]
+
+			copy(block, out[:semiblockSize])
+			copy(block[semiblockSize:], r)
+
+			b, err := a.EncryptBlock(block)
+
+			if err != nil {
+				return nil, err
+			}
+
+			// A = MSB(64, B) ^ t, where t = (n*j)+i.
+			binary.BigEndian.PutUint64(out, binary.BigEndian.Uint64(b)^uint64(n*j+i))
+			copy(r, b[semiblockSize:])
+		}
+	}
+
+	return out, nil
+}
+
+// Unwrap is the unwrapping process W^-1 shared by KW and KWP.
+// It returns the recovered IV, which has to be checked by the caller.
+//
+// https://www.rfc-editor.org/rfc/rfc3394#section-2.2.2
+func (a *AES256) unwrap(wrappedKey []byte) ([]byte, []byte, error) {
+	n := len(wrappedKey)/semiblockSize - 1
+
+	out := make([]byte, len(wrappedKey))
+	copy(out, wrappedKey)
+
+	block := make([]byte, consts.BLOCK_SIZE)
+
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			r := out[i*semiblockSize : (i+1)*semiblockSize]
+
+			// B = AES-1(K, (A ^ t) | R[i]), where t = n*j+i.
+			binary.BigEndian.PutUint64(block, binary.BigEndian.Uint64(out)^uint64(n*j+i))
+			copy(block[semiblockSize:], r)
+
+			b, err := a.DecryptBlock(block)
+
+			if err != nil {
+				return nil, nil, err
+			}
+
+			copy(out, b[:semiblockSize])
+			copy(r, b[semiblockSize:])
+		}
+	}
+
+	return out[:semiblockSize], out[semiblockSize:], nil
+}
@@ -0,0 +1,248 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Test vectors from RFC 3394 Section 4.
+var kwTests = []struct {
+	name       string
+	kek        string
+	keyData    string
+	wrappedKey string
+}{
+	{
+		name:       "4.1 Wrap 128 bits of Key Data with a 128-bit KEK",
+		kek:        "000102030405060708090A0B0C0D0E0F",
+		keyData:    "00112233445566778899AABBCCDDEEFF",
+		wrappedKey: "1FA68B0A8112B447 AEF34BD8FB5A7B82 9D3E862371D2CFE5",
+	},
+	{
+		name:       "4.3 Wrap 128 bits of Key Data with a 256-bit KEK",
+		kek:        "000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F",
+		keyData:    "00112233445566778899AABBCCDDEEFF",
+		wrappedKey: "64E8C3F9CE0F5BA2 63E9777905818A2A 93C8191E7D6E8AE7",
+	},
+	{
+		name:       "4.5 Wrap 192 bits of Key Data with a 256-bit KEK",
+		kek:        "000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F",
+		keyData:    "00112233445566778899AABBCCDDEEFF0001020304050607",
+		wrappedKey: "A8F9BC1612C68B3F F6E6F4FBE30E71E4 769C8B80A32CB895 8CD5D17D6B254DA1",
+	},
+	{
+		name:       "4.6 Wrap 256 bits of Key Data with a 256-bit KEK",
+		kek:        "000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F",
+		keyData:    "00112233445566778899AABBCCDDEEFF000102030405060708090A0B0C0D0E0F",
+		wrappedKey: "28C9F404C4B810F4 CBCCB35CFB87F826 3F5786E2D80ED326 CBC7F0E71A99F43B FB988B9B7A02DD21",
+	},
+}
+
+// Test vectors from RFC 5649 Section 6. The RFC only
+// provides examples with a 192-bit KEK.
+var kwpTests = []struct {
+	name       string
+	kek        string
+	keyData    string
+	wrappedKey string
+}{
+	{
+		name:       "Wrap 20 octets with a 192-bit key",
+		kek:        "5840df6e29b02af1 ab493b705bf16ea1 ae8338f4dcc176a8",
+		keyData:    "c37b7e6492584340 bed1220780894115 5068f738",
+		wrappedKey: "138bdeaa9b8fa7fc 61f97742e72248ee 5ae6ae5360d1ae6a 5f54f373fa543b6a",
+	},
+	{
+		name:       "Wrap 7 octets with a 192-bit key",
+		kek:        "5840df6e29b02af1 ab493b705bf16ea1 ae8338f4dcc176a8",
+		keyData:    "466f7250617369",
+		wrappedKey: "afbeb0f07dfbf541 9200f2ccb50bb24f",
+	},
+}
+
+func TestKeyWrapVectors(t *testing.T) {
+	for _, test := range kwTests {
+		a, err := NewAES256FromKey(decodeHex(test.kek))
+		if err != nil {
+			panic(err)
+		}
+
+		actual, err := a.WrapKey(decodeHex(test.keyData))
+		if err != nil {
+			panic(err)
+		}
+
+		if !bytes.Equal(actual, decodeHex(test.wrappedKey)) {
+			t.Fatalf("FAILED: %s wrapping failed", test.name)
+		}
+
+		actual, err = a.UnwrapKey(actual)
+		if err != nil {
+			panic(err)
+		}
+
+		if !bytes.Equal(actual, decodeHex(test.keyData)) {
+			t.Fatalf("FAILED: %s unwrapping failed", test.name)
+		}
+	}
+}
+
+func TestKeyWrapPaddedVectors(t *testing.T) {
+	for _, test := range kwpTests {
+		a, err := NewAES256FromKey(decodeHex(test.kek))
+		if err != nil {
+			panic(err)
+		}
+
+		actual, err := a.WrapKeyPadded(decodeHex(test.keyData))
+		if err != nil {
+			panic(err)
+		}
+
+		if !bytes.Equal(actual, decodeHex(test.wrappedKey)) {
+			t.Fatalf("FAILED: %s wrapping failed", test.name)
+		}
+
+		actual, err = a.UnwrapKeyPadded(actual)
+		if err != nil {
+			panic(err)
+		}
+
+		if !bytes.Equal(actual, decodeHex(test.keyData)) {
+			t.Fatalf("FAILED: %s unwrapping failed", test.name)
+		}
+	}
+}
+
+func TestKeyWrapIntegrity(t *testing.T) {
+	a, err := NewAES256FromKey(decodeHex(kwTests[3].kek))
+	if err != nil {
+		panic(err)
+	}
+
+	wrappedKey := decodeHex(kwTests[3].wrappedKey)
+
+	for i := range wrappedKey {
+		tampered := append([]byte{}, wrappedKey...)
+		tampered[i] ^= 0x80
+
+		if _, err := a.UnwrapKey(tampered); err == nil {
+			t.Fatalf("FAILED: tampered byte %d accepted", i)
+		}
+	}
+
+	// KW and KWP use different IVs, so one can't be unwrapped as the other.
+	if _, err := a.UnwrapKeyPadded(wrappedKey); err == nil {
+		t.Fatalf("FAILED: KW wrapped key accepted by KWP")
+	}
+
+	padded, err := a.WrapKeyPadded(decodeHex(kwTests[3].keyData))
+	if err != nil {
+		panic(err)
+	}
+
+	if _, err := a.UnwrapKey(padded); err == nil {
+		t.Fatalf("FAILED: KWP wrapped key accepted by KW")
+	}
+
+	for _, size := range []int{0, 8, 15, 17} {
+		if _, err := a.WrapKey(make([]byte, size)); err == nil {
+			t.Fatalf("FAILED: %d bytes of key data wrapped with KW", size)
+		}
+	}
+
+	for _, size := range []int{0, 8, 16, 23} {
+		if _, err := a.UnwrapKey(make([]byte, size)); err == nil {
+			t.Fatalf("FAILED: %d byte wrapped key accepted by KW", size)
+		}
+	}
+
+	for _, size := range []int{0, 8, 15, 17} {
+		if _, err := a.UnwrapKeyPadded(make([]byte, size)); err == nil {
+			t.Fatalf("FAILED: %d byte wrapped key accepted by KWP", size)
+		}
+	}
+
+	if _, err := a.WrapKeyPadded(nil); err == nil {
+		t.Fatalf("FAILED: empty key data wrapped with KWP")
+	}
+}
+
+func TestKeyWrapPadded(t *testing.T) {
+	a, err := NewAES256([]byte("key encryption key"))
+	if err != nil {
+		panic(err)
+	}
+
+	// Lengths around the single block case and the padding boundaries.
+	for length := 1; length <= 41; length++ {
+		keyData := bytes.Repeat([]byte{0x5a}, length)
+
+		wrappedKey, err := a.WrapKeyPadded(keyData)
+		if err != nil {
+			panic(err)
+		}
+
+		if len(wrappedKey) != (length+7)/8*8+8 {
+			t.Fatalf("FAILED: invalid wrapped key size for %d bytes", length)
+		}
+
+		actual, err := a.UnwrapKeyPadded(wrappedKey)
+		if err != nil || !bytes.Equal(actual, keyData) {
+			t.Fatalf("FAILED: KWP round trip failed for %d bytes", length)
+		}
+
+		tampered := append([]byte{}, wrappedKey...)
+		tampered[len(tampered)-1] ^= 0x01
+
+		if _, err := a.UnwrapKeyPadded(tampered); err == nil {
+			t.Fatalf("FAILED: tampered wrapped key accepted for %d bytes", length)
+		}
+	}
+
+	// Wrapped keys with invalid length indicators or non-zero
+	// padding have to be rejected even with a valid prefix.
+	for _, mli := range []uint32{0, 8, 17, 1 << 31} {
+		iv := append(append([]byte{}, kwpIV...), byte(mli>>24), byte(mli>>16), byte(mli>>8), byte(mli))
+
+		wrappedKey, err := a.wrap(iv, make([]byte, 16))
+		if err != nil {
+			panic(err)
+		}
+
+		if _, err := a.UnwrapKeyPadded(wrappedKey); err == nil {
+			t.Fatalf("FAILED: length indicator %d accepted", mli)
+		}
+	}
+
+	iv := append(append([]byte{}, kwpIV...), 0x00, 0x00, 0x00, 0x0c)
+
+	wrappedKey, err := a.wrap(iv, append(make([]byte, 15), 0x01))
+	if err != nil {
+		panic(err)
+	}
+
+	if _, err := a.UnwrapKeyPadded(wrappedKey); err == nil {
+		t.Fatalf("FAILED: non-zero padding accepted")
+	}
+}
@@ -0,0 +1,359 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+	"math/bits"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+)
+
+// Number of blocks masked and encrypted at once in OCB mode.
+const ocbBatchSize = 8
+
+// Ocb implements the cipher.AEAD interface in OCB3 mode.
+type ocb struct {
+	cipher    *AES256
+	nonceSize int
+	tagSize   int
+	lStar     []byte
+	lDollar   []byte
+	l         [][]byte
+}
+
+// NewOCB returns the cipher wrapped in OCB3 mode as a cipher.AEAD
+// with the given nonce and tag sizes.
+//
+// The nonce has to be 1 to 15 bytes long, the tag 1 to 16 bytes long.
+// OCB encrypts and authenticates in a single pass, which makes it
+// faster than GCM without hardware support for carry-less
+// multiplication.
+//
+// https://www.rfc-editor.org/rfc/rfc7253
+func (a *AES256) NewOCB(nonceSize, tagSize int) (cipher.AEAD, error) {
+	if nonceSize < 1 || nonceSize >= consts.BLOCK_SIZE {
+		return nil, errors.New("invalid nonce size")
+	}
+
+	if tagSize < 1 || tagSize > consts.TAG_SIZE {
+		return nil, errors.New("invalid tag size")
+	}
+
+	o := &ocb{cipher: a, nonceSize: nonceSize, tagSize: tagSize}
+
+	// L_* = ENCIPHER(K, zeros(128)), L_$ = double(L_*),
+	// L_0 = double(L_$) and L_i = double(L_{i-1}).
+	o.lStar = make([]byte, consts.BLOCK_SIZE)
+	a.encryptBlocks(o.lStar, o.lStar)
+	o.lDollar = dbl(o.lStar)
+
+	o.l = make([][]byte, bits.UintSize)
+	o.l[0] = dbl(o.lDollar)
+
+	for i := 1; i < len(o.l); i++ {
+		o.l[i] = dbl(o.l[i-1])
+	}
+
+	return o, nil
+}
+
+// Data encryption and authentication using OCB mode with a random 12 byte
+// nonce. Nonce is prepended to the cipherText and the 16 byte authentication
+// tag is appended to the cipherText.
+//
+// Both plainText and authData will be authenticated, but only plainText is encrypted.
+//
+// https://www.rfc-editor.org/rfc/rfc7253
+func (a *AES256) EncryptOCB(plainText []byte, authData []byte) ([]byte, error) {
+	aead, err := a.NewOCB(consts.NONCE_SIZE, consts.TAG_SIZE)
+
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plainText)+aead.Overhead())
+	if err := readRandom(a.rand, nonce, "nonce"); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plainText, authData), nil
+}
+
+// Data decryption and authentication using OCB mode. The 12 byte nonce
+// is prepended to the cipherText and the 16 byte authentication tag
+// is appended to the cipherText.
+//
+// Both cipherText and authData will be authenticated, but only cipherText is decrypted.
+//
+// https://www.rfc-editor.org/rfc/rfc7253
+func (a *AES256) DecryptOCB(cipherText []byte, authData []byte) ([]byte, error) {
+	aead, err := a.NewOCB(consts.NONCE_SIZE, consts.TAG_SIZE)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cipherText) < aead.NonceSize()+aead.Overhead() {
+		return nil, errors.New("OCB authentication failed: cipherText too short")
+	}
+
+	nonce := cipherText[:aead.NonceSize()]
+	return aead.Open(nil, nonce, cipherText[aead.NonceSize():], authData)
+}
+
+func (o *ocb) NonceSize() int {
+	return o.nonceSize
+}
+
+func (o *ocb) Overhead() int {
+	return o.tagSize
+}
+
+// Seal encrypts and authenticates plaintext, authenticates
+// additionalData and appends the result to dst.
+//
+// To reuse plaintext's storage for the output, use plaintext[:0] as dst.
+func (o *ocb) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != o.nonceSize {
+		panic("aes256go: incorrect nonce length given to OCB")
+	}
+
+	ret, out := sliceForAppend(dst, len(plaintext)+o.tagSize)
+
+	if inexactOverlap(out, plaintext) {
+		panic("aes256go: invalid buffer overlap")
+	}
+
+	tag := o.crypt(out, plaintext, nonce, additionalData, false)
+	copy(out[len(plaintext):], tag)
+
+	return ret
+}
+
+// Open decrypts and authenticates ciphertext, authenticates
+// additionalData and, if successful, appends the resulting
+// plaintext to dst.
+//
+// To reuse ciphertext's storage for the output, use ciphertext[:0] as dst.
+func (o *ocb) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != o.nonceSize {
+		panic("aes256go: incorrect nonce length given to OCB")
+	}
+
+	if len(ciphertext) < o.tagSize {
+		return nil, errors.New("OCB authentication failed: cipherText too short")
+	}
+
+	tag := ciphertext[len(ciphertext)-o.tagSize:]
+	ciphertext = ciphertext[:len(ciphertext)-o.tagSize]
+
+	ret, out := sliceForAppend(dst, len(ciphertext))
+
+	if inexactOverlap(out, ciphertext) {
+		panic("aes256go: invalid buffer overlap")
+	}
+
+	// OCB authenticates the plainText checksum, so it has to
+	// be decrypted first. It is wiped if the tag does not match.
+	testTag := o.crypt(out, ciphertext, nonce, additionalData, true)
+
+	if subtle.ConstantTimeCompare(tag, testTag) != 1 {
+		for i := range out {
+			out[i] = 0x00
+		}
+
+		return nil, errors.New("OCB authentication failed: Invalid authentication tag")
+	}
+
+	return ret, nil
+}
+
+// Crypt encrypts or decrypts src into dst and returns the tag.
+//
+// https://www.rfc-editor.org/rfc/rfc7253#section-4.2
+func (o *ocb) crypt(dst, src, nonce, authData []byte, decrypt bool) []byte {
+	offset := o.initialOffset(nonce)
+	checksum := make([]byte, consts.BLOCK_SIZE)
+	offsets := make([]byte, ocbBatchSize*consts.BLOCK_SIZE)
+
+	full := len(src) / consts.BLOCK_SIZE * consts.BLOCK_SIZE
+
+	for i := 0; i < full; i += len(offsets) {
+		end := i + len(offsets)
+		if end > full {
+			end = full
+		}
+
+		// Offset_i = Offset_{i-1} xor L_{ntz(i)}
+		for j := i; j < end; j += consts.BLOCK_SIZE {
+			xorBlock(offset, o.l[bits.TrailingZeros(uint(j/consts.BLOCK_SIZE+1))])
+			copy(offsets[j-i:], offset)
+
+			if !decrypt {
+				xorBlock(checksum, src[j:])
+			}
+		}
+
+		for j := i; j < end; j++ {
+			dst[j] = src[j] ^ offsets[j-i]
+		}
+
+		if decrypt {
+			o.cipher.decryptBlocks(dst[i:end], dst[i:end])
+		} else {
+			o.cipher.encryptBlocks(dst[i:end], dst[i:end])
+		}
+
+		for j := i; j < end; j++ {
+			dst[j] ^= offsets[j-i]
+		}
+
+		if decrypt {
+			for j := i; j < end; j += consts.BLOCK_SIZE {
+				xorBlock(checksum, dst[j:])
+			}
+		}
+	}
+
+	// The final partial block is encrypted with a pad
+	// and added to the checksum padded with 10^i.
+	if full < len(src) {
+		xorBlock(offset, o.lStar)
+
+		pad := make([]byte, consts.BLOCK_SIZE)
+		o.cipher.encryptBlocks(pad, offset)
+
+		for j := full; j < len(src); j++ {
+			b := src[j]
+			dst[j] = b ^ pad[j-full]
+
+			if decrypt {
+				b = dst[j]
+			}
+
+			checksum[j-full] ^= b
+		}
+
+		checksum[len(src)-full] ^= 0x80
+	}
+
+	// Tag = ENCIPHER(K, Checksum xor Offset xor L_$) xor HASH(K, A)
+	xorBlock(checksum, offset)
+	xorBlock(checksum, o.lDollar)
+	o.cipher.encryptBlocks(checksum, checksum)
+	xorBlock(checksum, o.hash(authData))
+
+	return checksum[:o.tagSize]
+}
+
+// InitialOffset derives Offset_0 from the nonce.
+//
+// https://www.rfc-editor.org/rfc/rfc7253#section-4.2
+func (o *ocb) initialOffset(nonce []byte) []byte {
+	// Nonce = num2str(TAGLEN mod 128, 7) || zeros || 1 || N
+	n := make([]byte, consts.BLOCK_SIZE)
+	n[0] = byte(8*o.tagSize%128) << 1
+	n[consts.BLOCK_SIZE-1-len(nonce)] |= 0x01
+	copy(n[consts.BLOCK_SIZE-len(nonce):], nonce)
+
+	bottom := int(n[consts.BLOCK_SIZE-1] & 0x3f)
+	n[consts.BLOCK_SIZE-1] &^= 0x3f
+
+	ktop := make([]byte, consts.BLOCK_SIZE)
+	o.cipher.encryptBlocks(ktop, n)
+
+	// Stretch = Ktop || (Ktop[1..64] xor Ktop[9..72])
+	stretch := make([]byte, consts.BLOCK_SIZE+8)
+	copy(stretch, ktop)
+
+	for i := 0; i < 8; i++ {
+		stretch[consts.BLOCK_SIZE+i] = ktop[i] ^ ktop[i+1]
+	}
+
+	// Offset_0 = Stretch[1+bottom..128+bottom]
+	offset := make([]byte, consts.BLOCK_SIZE)
+	byteShift, bitShift := bottom/8, uint(bottom%8)
+
+	for i := range offset {
+		offset[i] = stretch[i+byteShift]<<bitShift | stretch[i+byteShift+1]>>(8-bitShift)
+	}
+
+	return offset
+}
+
+// Hash processes the associated data.
+//
+// https://www.rfc-editor.org/rfc/rfc7253#section-4.1
+func (o *ocb) hash(authData []byte) []byte {
+	sum := make([]byte, consts.BLOCK_SIZE)
+	offset := make([]byte, consts.BLOCK_SIZE)
+	batch := make([]byte, ocbBatchSize*consts.BLOCK_SIZE)
+
+	full := len(authData) / consts.BLOCK_SIZE * consts.BLOCK_SIZE
+
+	for i := 0; i < full; i += len(batch) {
+		end := i + len(batch)
+		if end > full {
+			end = full
+		}
+
+		n := end - i
+
+		for j := i; j < end; j += consts.BLOCK_SIZE {
+			xorBlock(offset, o.l[bits.TrailingZeros(uint(j/consts.BLOCK_SIZE+1))])
+			copy(batch[j-i:], offset)
+			xorBlock(batch[j-i:], authData[j:])
+		}
+
+		o.cipher.encryptBlocks(batch[:n], batch[:n])
+
+		for j := 0; j < n; j += consts.BLOCK_SIZE {
+			xorBlock(sum, batch[j:])
+		}
+	}
+
+	if full < len(authData) {
+		xorBlock(offset, o.lStar)
+
+		for j := full; j < len(authData); j++ {
+			offset[j-full] ^= authData[j]
+		}
+
+		offset[len(authData)-full] ^= 0x80
+		o.cipher.encryptBlocks(offset, offset)
+		xorBlock(sum, offset)
+	}
+
+	return sum
+}
+
+// XorBlock sets dst ^= src for one block.
+func xorBlock(dst, src []byte) {
+	_ = dst[consts.BLOCK_SIZE-1]
+	_ = src[consts.BLOCK_SIZE-1]
+
+	for i := 0; i < consts.BLOCK_SIZE; i++ {
+		dst[i] ^= src[i]
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/binary"
+	"testing"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+)
+
+// Sample results from RFC 7253 Appendix A. They use a 128 bit key,
+// which this package supports when the cipher is created from a raw key.
+var ocbTests = []struct {
+	nonce      string
+	authData   string
+	plainText  string
+	cipherText string
+}{
+	{
+		nonce:      "bbaa99887766554433221100",
+		cipherText: "785407bfffc8ad9edcc5520ac9111ee6",
+	},
+	{
+		nonce:      "bbaa99887766554433221101",
+		authData:   "0001020304050607",
+		plainText:  "0001020304050607",
+		cipherText: "6820b3657b6f615a5725bda0d3b4eb3a257c9af1f8f03009",
+	},
+	{
+		nonce:      "bbaa99887766554433221102",
+		authData:   "0001020304050607",
+		cipherText: "81017f8203f081277152fade694a0a00",
+	},
+	{
+		nonce:      "bbaa99887766554433221103",
+		plainText:  "0001020304050607",
+		cipherText: "45dd69f8f5aae72414054cd1f35d82760b2cd00d2f99bfa9",
+	},
+	{
+		nonce:      "bbaa99887766554433221104",
+		authData:   "000102030405060708090a0b0c0d0e0f",
+		plainText:  "000102030405060708090a0b0c0d0e0f",
+		cipherText: "571d535b60b277188be5147170a9a22c3ad7a4ff3835b8c5701c1ccec8fc3358",
+	},
+	{
+		nonce:      "bbaa99887766554433221105",
+		authData:   "000102030405060708090a0b0c0d0e0f",
+		cipherText: "8cf761b6902ef764462ad86498ca6b97",
+	},
+	{
+		nonce:      "bbaa99887766554433221106",
+		plainText:  "000102030405060708090a0b0c0d0e0f",
+		cipherText: "5ce88ec2e0692706a915c00aeb8b2396f40e1c743f52436bdf06d8fa1eca343d",
+	},
+}
+
+func TestOCBVectors(t *testing.T) {
+	a, err := NewAES256FromKey(decodeHex("000102030405060708090a0b0c0d0e0f"))
+	if err != nil {
+		panic(err)
+	}
+
+	aead, err := a.NewOCB(consts.NONCE_SIZE, consts.TAG_SIZE)
+	if err != nil {
+		panic(err)
+	}
+
+	for i, test := range ocbTests {
+		nonce := decodeHex(test.nonce)
+		expected := decodeHex(test.cipherText)
+
+		actual := aead.Seal(nil, nonce, decodeHex(test.plainText), decodeHex(test.authData))
+		if !bytes.Equal(actual, expected) {
+			t.Fatalf("FAILED: OCB sample %d encryption failed", i+1)
+		}
+
+		plainText, err := aead.Open(nil, nonce, expected, decodeHex(test.authData))
+		if err != nil || !bytes.Equal(plainText, decodeHex(test.plainText)) {
+			t.Fatalf("FAILED: OCB sample %d decryption failed", i+1)
+		}
+
+		expected[0] ^= 0x01
+
+		if _, err := aead.Open(nil, nonce, expected, decodeHex(test.authData)); err == nil {
+			t.Fatalf("FAILED: OCB sample %d accepted a modified cipherText", i+1)
+		}
+	}
+
+	// The last sample uses a different key and a 96 bit tag.
+	a, err = NewAES256FromKey(decodeHex("0f0e0d0c0b0a09080706050403020100"))
+	if err != nil {
+		panic(err)
+	}
+
+	aead, err = a.NewOCB(consts.NONCE_SIZE, 12)
+	if err != nil {
+		panic(err)
+	}
+
+	data := decodeHex("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f2021222324252627")
+	expected := decodeHex("1792a4e31e0755fb03e31b22116e6c2ddf9efd6e33d536f1a0124b0a55bae884" +
+		"ed93481529c76b6ad0c515f4d1cdd4fdac4f02aa")
+
+	if !bytes.Equal(aead.Seal(nil, decodeHex("bbaa9988776655443322110d"), data, data), expected) {
+		t.Fatalf("FAILED: OCB sample with a 96 bit tag failed")
+	}
+}
+
+// The iterated check from RFC 7253 Appendix A, which covers every
+// key size, several tag sizes and messages up to 127 bytes long.
+func TestOCBIterated(t *testing.T) {
+	tests := []struct {
+		keySize int
+		tagSize int
+		output  string
+	}{
+		{consts.KEY_SIZE_128, 16, "67e944d23256c5e0b6c61fa22fdf1ea2"},
+		{consts.KEY_SIZE_192, 16, "f673f2c3e7174aae7bae986ca9f29e17"},
+		{consts.KEY_SIZE_256, 16, "d90eb8e9c977c88b79dd793d7ffa161c"},
+		{consts.KEY_SIZE_128, 12, "77a3d8e73589158d25d01209"},
+		{consts.KEY_SIZE_192, 12, "05d56ead2752c86be6932c5e"},
+		{consts.KEY_SIZE_256, 12, "5458359ac23b0cba9e6330dd"},
+		{consts.KEY_SIZE_128, 8, "192c9b7bd90ba06a"},
+		{consts.KEY_SIZE_192, 8, "0066bc6e0ef34e24"},
+		{consts.KEY_SIZE_256, 8, "7d4ea5d445501cbe"},
+	}
+
+	for _, test := range tests {
+		k := make([]byte, test.keySize)
+		k[len(k)-1] = byte(8 * test.tagSize)
+
+		a, err := NewAES256FromKey(k)
+		if err != nil {
+			panic(err)
+		}
+
+		aead, err := a.NewOCB(consts.NONCE_SIZE, test.tagSize)
+		if err != nil {
+			panic(err)
+		}
+
+		nonce := func(n uint32) []byte {
+			return binary.BigEndian.AppendUint32(make([]byte, 8), n)
+		}
+
+		var c []byte
+
+		for i := uint32(0); i < 128; i++ {
+			s := make([]byte, i)
+
+			c = aead.Seal(c, nonce(3*i+1), s, s)
+			c = aead.Seal(c, nonce(3*i+2), s, nil)
+			c = aead.Seal(c, nonce(3*i+3), nil, s)
+		}
+
+		if !bytes.Equal(aead.Seal(nil, nonce(385), nil, c), decodeHex(test.output)) {
+			t.Fatalf("FAILED: OCB iterated check failed for %d byte key and %d byte tag", test.keySize, test.tagSize)
+		}
+	}
+}
+
+func TestOCBParameters(t *testing.T) {
+	a, err := NewAES256FromKey(bytes.Repeat([]byte{0x0c}, consts.KEY_SIZE))
+	if err != nil {
+		panic(err)
+	}
+
+	plainText := bytes.Repeat([]byte("single pass "), 30)
+	authData := bytes.Repeat([]byte("header"), 60)
+
+	for nonceSize := 1; nonceSize < consts.BLOCK_SIZE; nonceSize++ {
+		for _, tagSize := range []int{1, 4, 8, 12, consts.TAG_SIZE} {
+			aead, err := a.NewOCB(nonceSize, tagSize)
+			if err != nil {
+				t.Fatalf("FAILED: OCB(%d, %d) rejected: %v", nonceSize, tagSize, err)
+			}
+
+			nonce := bytes.Repeat([]byte{byte(nonceSize)}, nonceSize)
+
+			for _, size := range []int{0, 1, 16, 17, 127, 128, 129, len(plainText)} {
+				sealed := aead.Seal(nil, nonce, plainText[:size], authData[:size])
+
+				opened, err := aead.Open(nil, nonce, sealed, authData[:size])
+				if err != nil || !bytes.Equal(opened, plainText[:size]) {
+					t.Fatalf("FAILED: OCB(%d, %d) round trip failed for %d bytes", nonceSize, tagSize, size)
+				}
+
+				buf := append([]byte{}, plainText[:size]...)
+				inPlace := aead.Seal(buf[:0], nonce, buf, authData[:size])
+
+				if !bytes.Equal(inPlace, sealed) {
+					t.Fatalf("FAILED: OCB(%d, %d) in place Seal mismatch for %d bytes", nonceSize, tagSize, size)
+				}
+
+				opened, err = aead.Open(inPlace[:0], nonce, inPlace, authData[:size])
+				if err != nil || !bytes.Equal(opened, plainText[:size]) {
+					t.Fatalf("FAILED: OCB(%d, %d) in place Open failed for %d bytes", nonceSize, tagSize, size)
+				}
+			}
+		}
+	}
+
+	for _, params := range [][2]int{{0, 16}, {16, 16}, {12, 0}, {12, 17}} {
+		if _, err := a.NewOCB(params[0], params[1]); err == nil {
+			t.Fatalf("FAILED: OCB(%d, %d) accepted", params[0], params[1])
+		}
+	}
+
+	cipherText, err := a.EncryptOCB(plainText, authData)
+	if err != nil {
+		panic(err)
+	}
+
+	decrypted, err := a.DecryptOCB(cipherText, authData)
+	if err != nil || !bytes.Equal(decrypted, plainText) {
+		t.Fatalf("FAILED: OCB round trip failed")
+	}
+
+	cipherText[len(cipherText)-1] ^= 0x01
+
+	if _, err := a.DecryptOCB(cipherText, authData); err == nil {
+		t.Fatalf("FAILED: OCB accepted a forged tag")
+	}
+}
+
+func benchmarkSeal(b *testing.B, newAEAD func(*AES256) (cipher.AEAD, error)) {
+	a, err := NewAES256FromKey(make([]byte, consts.KEY_SIZE))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	aead, err := newAEAD(a)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	data := make([]byte, 8192)
+	out := make([]byte, 0, len(data)+aead.Overhead())
+
+	b.SetBytes(int64(len(data)))
+
+	for i := 0; i < b.N; i++ {
+		aead.Seal(out, nonce, data, nil)
+	}
+}
+
+func BenchmarkSealOCB(b *testing.B) {
+	benchmarkSeal(b, func(a *AES256) (cipher.AEAD, error) { return a.NewOCB(consts.NONCE_SIZE, consts.TAG_SIZE) })
+}
+
+func BenchmarkSealGCM(b *testing.B) {
+	benchmarkSeal(b, func(a *AES256) (cipher.AEAD, error) { return a.NewGCM() })
+}
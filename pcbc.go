@@ -0,0 +1,71 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"errors"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+	"github.com/wedkarz02/aes256go/src/padding"
+)
+
+// Data encryption using PCBC mode.
+//
+// https://en.wikipedia.org/wiki/Block_cipher_mode_of_operation#Propagating_cipher_block_chaining_(PCBC)
+func (a *AES256) EncryptPCBC(plainText []byte, pad padding.Pad) ([]byte, error) {
+	iv := make([]byte, consts.IV_SIZE)
+	if err := readRandom(a.rand, iv, "iv"); err != nil {
+		return nil, err
+	}
+
+	return a.EncryptPCBCWithIV(iv, plainText, pad)
+}
+
+// Data encryption using PCBC mode with the given IV,
+// which is prepended to the cipherText.
+//
+// The first block is encrypted exactly like in CBC, so the IV has to
+// be unpredictable for the same reason. PCBC adds no integrity of its
+// own, combine it with a MAC when the cipherText can be modified.
+//
+// https://en.wikipedia.org/wiki/Block_cipher_mode_of_operation#Propagating_cipher_block_chaining_(PCBC)
+func (a *AES256) EncryptPCBCWithIV(iv []byte, plainText []byte, pad padding.Pad) ([]byte, error) {
+	if len(iv) != consts.IV_SIZE {
+		return nil, errors.New("invalid iv size")
+	}
+
+	return a.encryptChain(pcbcMode{iv: iv}, iv, plainText, pad)
+}
+
+// Data decryption using PCBC mode.
+//
+// Unlike CBC, an error in one cipherText block garbles all of
+// the following plainText blocks.
+//
+// https://en.wikipedia.org/wiki/Block_cipher_mode_of_operation#Propagating_cipher_block_chaining_(PCBC)
+func (a *AES256) DecryptPCBC(cipherText []byte, unpad padding.UnPad) ([]byte, error) {
+	if len(cipherText) < consts.IV_SIZE {
+		return nil, errors.New("cipherText size not matching the block size")
+	}
+
+	iv := cipherText[:consts.IV_SIZE]
+	return a.decryptChain(pcbcMode{iv: iv}, cipherText[consts.IV_SIZE:], unpad)
+}
@@ -0,0 +1,249 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+	"github.com/wedkarz02/aes256go/src/padding"
+)
+
+// There are no published PCBC test vectors. This one is derived from
+// the CBC-AES256 example in NIST SP 800-38A F.2.5: with the same key
+// and IV, PCBC feeds the block cipher the same inputs as that example
+// when every plainText block is the XOR of the example's plainText
+// blocks up to it. The cipherText then has to match the published one.
+const (
+	pcbcPlainText = "6bc1bee22e409f96e93d7e117393172a c5ec34b53043330a778a11bd363c997b" +
+		"f52428f3931fd71b9271d0a42c36cb94 03bb0cb64c504c0c3f5a91dfca5afc84"
+	pcbcCipherText = "f58c4c04d6e5f1ba779eabfb5f7bfbd6 9cfc4e967edb808d679f777bc6702c7d" +
+		"39f23369a9d9bacfa530e26304231461 b2eb05e2c39be9fcda6c19078c6a9d1b"
+)
+
+func TestPCBCVectors(t *testing.T) {
+	a, err := NewAES256FromKey(decodeHex(sp80038aKey))
+	if err != nil {
+		panic(err)
+	}
+
+	noPad := func(data []byte) []byte { return data }
+	noUnpad := func(data []byte) ([]byte, error) { return data, nil }
+
+	iv := decodeHex(sp80038aIV)
+	expected := append(decodeHex(sp80038aIV), decodeHex(pcbcCipherText)...)
+
+	cipherText, err := a.EncryptPCBCWithIV(iv, decodeHex(pcbcPlainText), noPad)
+	if err != nil {
+		panic(err)
+	}
+
+	if !bytes.Equal(cipherText, expected) {
+		t.Fatalf("FAILED: PCBC encryption failed")
+	}
+
+	actual, err := a.DecryptPCBC(expected, noUnpad)
+	if err != nil || !bytes.Equal(actual, decodeHex(pcbcPlainText)) {
+		t.Fatalf("FAILED: PCBC decryption failed")
+	}
+}
+
+func TestPCBC(t *testing.T) {
+	a, err := NewAES256([]byte("pcbc key"))
+	if err != nil {
+		panic(err)
+	}
+
+	iv := make([]byte, consts.IV_SIZE)
+	for i := range iv {
+		iv[i] = byte(i)
+	}
+
+	data := bytes.Repeat([]byte("propagating cbc "), 7)
+
+	for _, length := range []int{0, 1, 15, 16, 17, 31, 32, 33, 100} {
+		cipherText, err := a.EncryptPCBCWithIV(iv, data[:length], padding.PKCS7Padding)
+		if err != nil {
+			panic(err)
+		}
+
+		if len(cipherText) != consts.IV_SIZE+(length/consts.BLOCK_SIZE+1)*consts.BLOCK_SIZE {
+			t.Fatalf("FAILED: PCBC cipherText length wrong for %d bytes", length)
+		}
+
+		actual, err := a.DecryptPCBC(cipherText, padding.PKCS7Unpadding)
+		if err != nil {
+			panic(err)
+		}
+
+		if !bytes.Equal(actual, data[:length]) {
+			t.Fatalf("FAILED: PCBC round trip failed for %d bytes", length)
+		}
+	}
+
+	plainText := []byte("Some plainText that spans several blocks")
+
+	cipherText, err := a.EncryptPCBC(plainText, padding.PKCS7Padding)
+	if err != nil {
+		panic(err)
+	}
+
+	actual, err := a.DecryptPCBC(cipherText, padding.PKCS7Unpadding)
+	if err != nil || !bytes.Equal(actual, plainText) {
+		t.Fatalf("FAILED: PCBC round trip with random IV failed")
+	}
+
+	if _, err := a.EncryptPCBCWithIV(iv[1:], plainText, padding.PKCS7Padding); err == nil {
+		t.Fatalf("FAILED: invalid iv accepted")
+	}
+
+	if _, err := a.DecryptPCBC(cipherText[:consts.IV_SIZE-1], padding.PKCS7Unpadding); err == nil {
+		t.Fatalf("FAILED: short cipherText accepted")
+	}
+
+	if _, err := a.DecryptPCBC(cipherText[1:], padding.PKCS7Unpadding); err == nil {
+		t.Fatalf("FAILED: partial block accepted")
+	}
+}
+
+func TestPCBCErrorPropagation(t *testing.T) {
+	a, err := NewAES256([]byte("pcbc key"))
+	if err != nil {
+		panic(err)
+	}
+
+	noPad := func(data []byte) []byte { return data }
+	noUnpad := func(data []byte) ([]byte, error) { return data, nil }
+
+	iv := make([]byte, consts.IV_SIZE)
+	plainText := make([]byte, 5*consts.BLOCK_SIZE)
+
+	cipherText, err := a.EncryptPCBCWithIV(iv, plainText, noPad)
+	if err != nil {
+		panic(err)
+	}
+
+	// A changed cipherText block garbles its own plainText
+	// block and every block after it, but none before it.
+	for b := 0; b < 5; b++ {
+		tampered := append([]byte{}, cipherText...)
+		tampered[consts.IV_SIZE+b*consts.BLOCK_SIZE] ^= 0x01
+
+		actual, err := a.DecryptPCBC(tampered, noUnpad)
+		if err != nil {
+			panic(err)
+		}
+
+		for i := 0; i < 5; i++ {
+			garbled := !bytes.Equal(actual[i*consts.BLOCK_SIZE:(i+1)*consts.BLOCK_SIZE], plainText[i*consts.BLOCK_SIZE:(i+1)*consts.BLOCK_SIZE])
+
+			if garbled != (i >= b) {
+				t.Fatalf("FAILED: error in block %d propagated incorrectly to block %d", b, i)
+			}
+		}
+	}
+
+	// Swapping two adjacent cipherText blocks only garbles those two
+	// blocks, since P_i ^ C_i is carried over the same way.
+	swapped := append([]byte{}, cipherText...)
+	first := swapped[consts.IV_SIZE+consts.BLOCK_SIZE : consts.IV_SIZE+2*consts.BLOCK_SIZE]
+	second := swapped[consts.IV_SIZE+2*consts.BLOCK_SIZE : consts.IV_SIZE+3*consts.BLOCK_SIZE]
+
+	tmp := append([]byte{}, first...)
+	copy(first, second)
+	copy(second, tmp)
+
+	actual, err := a.DecryptPCBC(swapped, noUnpad)
+	if err != nil {
+		panic(err)
+	}
+
+	if !bytes.Equal(actual[3*consts.BLOCK_SIZE:], plainText[3*consts.BLOCK_SIZE:]) {
+		t.Fatalf("FAILED: swapped blocks garbled the following blocks")
+	}
+}
+
+func TestPCBCTamperedPadding(t *testing.T) {
+	a, err := NewAES256([]byte("pcbc key"))
+	if err != nil {
+		panic(err)
+	}
+
+	// A fixed IV keeps the garbled padding deterministic.
+	iv := make([]byte, consts.IV_SIZE)
+	plainText := []byte("hello")
+
+	cipherText, err := a.EncryptPCBCWithIV(iv, plainText, padding.PKCS7Padding)
+	if err != nil {
+		panic(err)
+	}
+
+	// Every changed bit of the cipherText block garbles the padding,
+	// which has to be reported as an error instead of a panic.
+	for i := consts.IV_SIZE; i < len(cipherText); i++ {
+		for bit := 0; bit < 8; bit++ {
+			tampered := append([]byte{}, cipherText...)
+			tampered[i] ^= 1 << bit
+
+			actual, err := a.DecryptPCBC(tampered, padding.PKCS7Unpadding)
+
+			if err == nil && bytes.Equal(actual, plainText) {
+				t.Fatalf("FAILED: tampered byte %d bit %d went unnoticed", i, bit)
+			}
+		}
+	}
+
+	tampered := append([]byte{}, cipherText...)
+	tampered[consts.IV_SIZE] ^= 0x01
+
+	if _, err := a.DecryptPCBC(tampered, padding.PKCS7Unpadding); err == nil {
+		t.Fatalf("FAILED: invalid padding accepted")
+	}
+
+	for _, mode := range []string{"ECB", "CBC"} {
+		var cipherText []byte
+
+		switch mode {
+		case "ECB":
+			cipherText, err = a.EncryptECB(plainText, padding.PKCS7Padding)
+		case "CBC":
+			cipherText, err = a.EncryptCBCWithIV(iv, plainText, padding.PKCS7Padding)
+		}
+
+		if err != nil {
+			panic(err)
+		}
+
+		cipherText[len(cipherText)-1] ^= 0x01
+
+		switch mode {
+		case "ECB":
+			_, err = a.DecryptECB(cipherText, padding.PKCS7Unpadding)
+		case "CBC":
+			_, err = a.DecryptCBC(cipherText, padding.PKCS7Unpadding)
+		}
+
+		if err == nil {
+			t.Fatalf("FAILED: %s invalid padding accepted", mode)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+
+	"github.com/wedkarz02/aes256go/src/key"
+)
+
+// RandError is returned when the source of randomness fails
+// to provide enough bytes for an IV, a nonce or a key.
+type RandError struct {
+	// What was being generated ("iv", "nonce" or "key").
+	Op  string
+	Err error
+}
+
+func (e *RandError) Error() string {
+	return e.Op + " initialization failed: " + e.Err.Error()
+}
+
+func (e *RandError) Unwrap() error {
+	return e.Err
+}
+
+// WithRand sets the source of randomness used to generate
+// IVs and nonces. It defaults to crypto/rand.Reader.
+//
+// A deterministic reader makes the ciphertexts reproducible,
+// which is only ever acceptable in tests.
+func WithRand(r io.Reader) Option {
+	return func(a *AES256) error {
+		if r == nil {
+			return errors.New("invalid source of randomness")
+		}
+
+		a.rand = r
+		return nil
+	}
+}
+
+// GenerateKey returns a new random key of the given size
+// read from r, or from crypto/rand.Reader if r is nil.
+//
+// The size has to be 16, 24 or 32 bytes, the result can
+// be used with NewAES256FromKey.
+func GenerateKey(r io.Reader, size int) ([]byte, error) {
+	if _, err := key.Rounds(size); err != nil {
+		return nil, err
+	}
+
+	if r == nil {
+		r = rand.Reader
+	}
+
+	k := make([]byte, size)
+	if err := readRandom(r, k, "key"); err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// ReadRandom fills buf with bytes from r, wrapping
+// any failure in a RandError.
+func readRandom(r io.Reader, buf []byte, op string) error {
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return &RandError{Op: op, Err: err}
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+	"github.com/wedkarz02/aes256go/src/padding"
+)
+
+// CountingReader is a deterministic source of "randomness"
+// returning consecutive byte values.
+type countingReader struct {
+	next byte
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = r.next
+		r.next++
+	}
+
+	return len(p), nil
+}
+
+func TestWithRandReproducible(t *testing.T) {
+	k := bytes.Repeat([]byte{0x2a}, consts.KEY_SIZE)
+	plainText := []byte("reproducible ciphertexts for test suites")
+
+	encrypt := func() [][]byte {
+		a, err := NewAES256FromKey(k, WithRand(&countingReader{}))
+		if err != nil {
+			panic(err)
+		}
+
+		var out [][]byte
+
+		for _, fn := range []func() ([]byte, error){
+			func() ([]byte, error) { return a.EncryptCBC(plainText, padding.PKCS7Padding) },
+			func() ([]byte, error) { return a.EncryptCFB(plainText, 3) },
+			func() ([]byte, error) { return a.EncryptOFB(plainText) },
+			func() ([]byte, error) { return a.EncryptCTR(plainText) },
+			func() ([]byte, error) { return a.EncryptGCM(plainText, nil) },
+		} {
+			cipherText, err := fn()
+			if err != nil {
+				t.Fatalf("FAILED: encryption with a custom reader failed: %v", err)
+			}
+
+			out = append(out, cipherText)
+		}
+
+		return out
+	}
+
+	first, second := encrypt(), encrypt()
+
+	for i := range first {
+		if !bytes.Equal(first[i], second[i]) {
+			t.Fatalf("FAILED: ciphertext %d is not reproducible", i)
+		}
+	}
+
+	// The CBC IV is the first block read from the reader.
+	expectedIV := make([]byte, consts.IV_SIZE)
+	(&countingReader{}).Read(expectedIV)
+
+	if !bytes.Equal(first[0][:consts.IV_SIZE], expectedIV) {
+		t.Fatalf("FAILED: CBC did not use the custom reader")
+	}
+}
+
+func TestWithRandErrors(t *testing.T) {
+	k := make([]byte, consts.KEY_SIZE)
+
+	if _, err := NewAES256FromKey(k, WithRand(nil)); err == nil {
+		t.Fatalf("FAILED: nil reader accepted")
+	}
+
+	// Not enough bytes for a single IV.
+	a, err := NewAES256FromKey(k, WithRand(bytes.NewReader(make([]byte, consts.IV_SIZE-1))))
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = a.EncryptCBC([]byte("short"), padding.PKCS7Padding)
+
+	var randErr *RandError
+	if !errors.As(err, &randErr) || randErr.Op != "iv" {
+		t.Fatalf("FAILED: expected an iv RandError, got %v", err)
+	}
+
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("FAILED: RandError does not wrap the reader error")
+	}
+
+	_, err = a.EncryptGCM([]byte("short"), nil)
+
+	if !errors.As(err, &randErr) || randErr.Op != "nonce" {
+		t.Fatalf("FAILED: expected a nonce RandError, got %v", err)
+	}
+}
+
+func TestGenerateKey(t *testing.T) {
+	for _, size := range []int{consts.KEY_SIZE_128, consts.KEY_SIZE_192, consts.KEY_SIZE_256} {
+		k, err := GenerateKey(nil, size)
+		if err != nil || len(k) != size {
+			t.Fatalf("FAILED: could not generate a %d byte key", size)
+		}
+
+		if _, err := NewAES256FromKey(k); err != nil {
+			t.Fatalf("FAILED: generated key rejected: %v", err)
+		}
+	}
+
+	k, err := GenerateKey(&countingReader{next: 1}, consts.KEY_SIZE_128)
+	if err != nil || k[0] != 1 || k[consts.KEY_SIZE_128-1] != consts.KEY_SIZE_128 {
+		t.Fatalf("FAILED: GenerateKey did not use the custom reader")
+	}
+
+	if _, err := GenerateKey(nil, 20); err == nil {
+		t.Fatalf("FAILED: invalid key size accepted")
+	}
+
+	var randErr *RandError
+	if _, err := GenerateKey(bytes.NewReader(nil), consts.KEY_SIZE); !errors.As(err, &randErr) {
+		t.Fatalf("FAILED: expected a key RandError, got %v", err)
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"crypto/subtle"
+	"errors"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+	"github.com/wedkarz02/aes256go/src/counter"
+)
+
+// Maximum number of associated data components
+// accepted by S2V, besides the plainText.
+const sivMaxAuthData = 126
+
+// SIV implements the deterministic authenticated encryption
+// mode AES-SIV. It uses two keys: the first one for the S2V
+// construction based on CMAC, the second one for CTR mode.
+//
+// https://www.rfc-editor.org/rfc/rfc5297
+type SIV struct {
+	mac    *AES256
+	cipher *AES256
+}
+
+// NewSIV initializes new AES-SIV cipher with a combined raw key.
+//
+// The key has to be 64 bytes long for AES-SIV-512, which uses two
+// AES-256 keys. 32 and 48 byte keys select AES-SIV-256 and
+// AES-SIV-384 respectively.
+func NewSIV(k []byte, opts ...Option) (*SIV, error) {
+	if len(k) != 2*consts.KEY_SIZE_128 && len(k) != 2*consts.KEY_SIZE_192 && len(k) != 2*consts.KEY_SIZE_256 {
+		return nil, errors.New("invalid key size")
+	}
+
+	mac, err := NewAES256FromKey(k[:len(k)/2], opts...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := NewAES256FromKey(k[len(k)/2:], opts...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &SIV{mac: mac, cipher: c}, nil
+}
+
+// ClearKey sets all bytes of both keys to 0x00
+// to make sure that they can't be retrieved from memory.
+func (s *SIV) ClearKey() {
+	s.mac.ClearKey()
+	s.cipher.ClearKey()
+}
+
+// Encrypt performs deterministic authenticated encryption of plainText.
+// The 16 byte synthetic IV is prepended to the cipherText.
+//
+// Every authData component is authenticated separately, so their
+// boundaries matter. To use AES-SIV as a nonce based scheme, pass
+// the nonce as the last component.
+//
+// Encrypting the same plainText with the same authData always gives
+// the same cipherText, which only reveals that the messages are equal.
+//
+// https://www.rfc-editor.org/rfc/rfc5297#section-2.6
+func (s *SIV) Encrypt(plainText []byte, authData ...[]byte) ([]byte, error) {
+	if len(authData) > sivMaxAuthData {
+		return nil, errors.New("too many associated data components")
+	}
+
+	v := s.s2v(authData, plainText)
+
+	cipherText, err := s.cipher.coreBlockCTR(plainText, newSIVCounter(v))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return append(v, cipherText...), nil
+}
+
+// Decrypt performs deterministic authenticated decryption of cipherText
+// with the synthetic IV prepended. The authData components have to
+// be the same as the ones used for encryption.
+//
+// https://www.rfc-editor.org/rfc/rfc5297#section-2.7
+func (s *SIV) Decrypt(cipherText []byte, authData ...[]byte) ([]byte, error) {
+	if len(authData) > sivMaxAuthData {
+		return nil, errors.New("too many associated data components")
+	}
+
+	if len(cipherText) < consts.BLOCK_SIZE {
+		return nil, errors.New("SIV authentication failed: cipherText too short")
+	}
+
+	v := cipherText[:consts.BLOCK_SIZE]
+
+	plainText, err := s.cipher.coreBlockCTR(cipherText[consts.BLOCK_SIZE:], newSIVCounter(v))
+
+	if err != nil {
+		return nil, err
+	}
+
+	// S2V is calculated over the plainText, so it has to be
+	// decrypted first, but it is only released after the
+	// synthetic IV has been verified.
+	if subtle.ConstantTimeCompare(v, s.s2v(authData, plainText)) != 1 {
+		for i := range plainText {
+			plainText[i] = 0x00
+		}
+
+		return nil, errors.New("SIV authentication failed: Invalid synthetic IV")
+	}
+
+	if plainText == nil {
+		plainText = []byte{}
+	}
+
+	return plainText, nil
+}
+
+// S2V turns the vector of strings into a single block
+// using CMAC and doubling.
+//
+// https://www.rfc-editor.org/rfc/rfc5297#section-2.4
+func (s *SIV) s2v(authData [][]byte, plainText []byte) []byte {
+	mac := newCMAC(s.mac)
+
+	mac.Write(make([]byte, consts.BLOCK_SIZE))
+	d := mac.Sum(nil)
+
+	for _, data := range authData {
+		mac.Reset()
+		mac.Write(data)
+		d = dbl(d)
+		xorBlock(d, mac.Sum(nil))
+	}
+
+	mac.Reset()
+
+	if len(plainText) >= consts.BLOCK_SIZE {
+		// T = Sn xorend D
+		n := len(plainText) - consts.BLOCK_SIZE
+		mac.Write(plainText[:n])
+
+		last := make([]byte, consts.BLOCK_SIZE)
+		copy(last, plainText[n:])
+		xorBlock(last, d)
+		mac.Write(last)
+	} else {
+		// T = dbl(D) xor pad(Sn)
+		t := dbl(d)
+
+		for i, b := range plainText {
+			t[i] ^= b
+		}
+
+		t[len(plainText)] ^= 0x80
+		mac.Write(t)
+	}
+
+	return mac.Sum(nil)
+}
+
+// NewSIVCounter returns a full 128 bit counter starting at the
+// synthetic IV with the 31st and 63rd bits (from the right)
+// cleared, so that the implementations using 32 or 64 bit
+// counters can't tell the difference.
+func newSIVCounter(v []byte) *counter.Counter {
+	q := make([]byte, consts.BLOCK_SIZE)
+	copy(q, v)
+	q[8] &= 0x7f
+	q[12] &= 0x7f
+
+	ctr, err := counter.NewCounterBlock(q, consts.BLOCK_SIZE)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return ctr
+}
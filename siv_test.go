@@ -0,0 +1,223 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"testing"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+)
+
+// Test vectors from RFC 5297 Appendix A. They use AES-SIV-256. RFC 5297
+// has no AES-SIV-512 examples, so those cipherTexts are checked against
+// refSIV once it reproduces the published ones.
+var sivTests = []struct {
+	name       string
+	key        string
+	authData   []string
+	plainText  string
+	cipherText string
+}{
+	{
+		name:       "Deterministic Authenticated Encryption Example",
+		key:        "fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0 f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff",
+		authData:   []string{"101112131415161718191a1b1c1d1e1f2021222324252627"},
+		plainText:  "112233445566778899aabbccddee",
+		cipherText: "85632d07c6e8f37f950acd320a2ecc93 40c02b9690c4dc04daef7f6afe5c",
+	},
+	{
+		name: "Nonce-Based Authenticated Encryption Example",
+		key:  "7f7e7d7c7b7a79787776757473727170 404142434445464748494a4b4c4d4e4f",
+		authData: []string{
+			"00112233445566778899aabbccddeeffdeaddadadeaddadaffeeddccbbaa99887766554433221100",
+			"102030405060708090a0",
+			"09f911029d74e35bd84156c5635688c0",
+		},
+		plainText: "7468697320697320736f6d6520706c61696e7465787420746f20656e6372797074207573696e67205349562d414553",
+		cipherText: "7bdb6e3b432667eb06f4d14bff2fbd0f cb900f2fddbe404326601965c889bf17" +
+			"dba77ceb094fa663b7a3f748ba8af829ea64ad544a272e9c485b62a3fd5c0d",
+	},
+}
+
+// RefSIV is a straightforward AES-SIV built on crypto/aes. It only
+// relies on the test-local refCMAC and refDbl helpers.
+func refSIV(k []byte, authData [][]byte, plainText []byte) []byte {
+	macBlock, err := aes.NewCipher(k[:len(k)/2])
+	if err != nil {
+		panic(err)
+	}
+
+	ctrBlock, err := aes.NewCipher(k[len(k)/2:])
+	if err != nil {
+		panic(err)
+	}
+
+	d := refCMAC(macBlock, make([]byte, consts.BLOCK_SIZE))
+
+	for _, data := range authData {
+		d = refDbl(d)
+		for i, b := range refCMAC(macBlock, data) {
+			d[i] ^= b
+		}
+	}
+
+	var t []byte
+
+	if len(plainText) >= consts.BLOCK_SIZE {
+		t = append([]byte{}, plainText...)
+		for i := range d {
+			t[len(t)-consts.BLOCK_SIZE+i] ^= d[i]
+		}
+	} else {
+		t = refDbl(d)
+		for i := range plainText {
+			t[i] ^= plainText[i]
+		}
+
+		t[len(plainText)] ^= 0x80
+	}
+
+	v := refCMAC(macBlock, t)
+
+	q := append([]byte{}, v...)
+	q[8] &= 0x7f
+	q[12] &= 0x7f
+
+	cipherText := make([]byte, len(plainText))
+	cipher.NewCTR(ctrBlock, q).XORKeyStream(cipherText, plainText)
+
+	return append(v, cipherText...)
+}
+
+func TestSIVVectors(t *testing.T) {
+	for _, test := range sivTests {
+		var authData [][]byte
+		for _, data := range test.authData {
+			authData = append(authData, decodeHex(data))
+		}
+
+		if !bytes.Equal(refSIV(decodeHex(test.key), authData, decodeHex(test.plainText)), decodeHex(test.cipherText)) {
+			t.Fatalf("FAILED: reference SIV does not match the %s", test.name)
+		}
+
+		// AES-SIV-256 with the original key and AES-SIV-512
+		// with both halves of the key doubled.
+		k := decodeHex(test.key)
+		k512 := append(append(append([]byte{}, k[:16]...), k[:16]...), append(k[16:], k[16:]...)...)
+
+		for _, key := range [][]byte{k, k512} {
+			s, err := NewSIV(key)
+			if err != nil {
+				panic(err)
+			}
+
+			expected := refSIV(key, authData, decodeHex(test.plainText))
+
+			actual, err := s.Encrypt(decodeHex(test.plainText), authData...)
+			if err != nil || !bytes.Equal(actual, expected) {
+				t.Fatalf("FAILED: AES-SIV-%d %s encryption failed", 8*len(key), test.name)
+			}
+
+			plainText, err := s.Decrypt(expected, authData...)
+			if err != nil || !bytes.Equal(plainText, decodeHex(test.plainText)) {
+				t.Fatalf("FAILED: AES-SIV-%d %s decryption failed", 8*len(key), test.name)
+			}
+
+			if _, err := s.Decrypt(expected, authData[:len(authData)-1]...); err == nil {
+				t.Fatalf("FAILED: AES-SIV-%d %s accepted a missing component", 8*len(key), test.name)
+			}
+
+			expected[len(expected)-1] ^= 0x01
+
+			if _, err := s.Decrypt(expected, authData...); err == nil {
+				t.Fatalf("FAILED: AES-SIV-%d %s accepted a modified cipherText", 8*len(key), test.name)
+			}
+		}
+	}
+}
+
+func TestSIVDeterministic(t *testing.T) {
+	k := make([]byte, 2*consts.KEY_SIZE)
+	for i := range k {
+		k[i] = byte(i)
+	}
+
+	s, err := NewSIV(k)
+	if err != nil {
+		panic(err)
+	}
+
+	defer s.ClearKey()
+
+	plainText := bytes.Repeat([]byte("deduplicated"), 4)
+
+	for _, size := range []int{0, 1, 15, 16, 17, 32, len(plainText)} {
+		first, err := s.Encrypt(plainText[:size], []byte("a"), []byte("b"))
+		if err != nil {
+			panic(err)
+		}
+
+		second, err := s.Encrypt(plainText[:size], []byte("a"), []byte("b"))
+		if err != nil {
+			panic(err)
+		}
+
+		if !bytes.Equal(first, second) || len(first) != consts.BLOCK_SIZE+size {
+			t.Fatalf("FAILED: SIV is not deterministic for %d bytes", size)
+		}
+
+		// The component boundaries are authenticated.
+		joined, err := s.Encrypt(plainText[:size], []byte("ab"))
+		if err != nil {
+			panic(err)
+		}
+
+		if bytes.Equal(first, joined) {
+			t.Fatalf("FAILED: SIV ignored the component boundaries")
+		}
+
+		decrypted, err := s.Decrypt(first, []byte("a"), []byte("b"))
+		if err != nil || !bytes.Equal(decrypted, plainText[:size]) {
+			t.Fatalf("FAILED: SIV round trip failed for %d bytes", size)
+		}
+
+		if !bytes.Equal(first, refSIV(k, [][]byte{[]byte("a"), []byte("b")}, plainText[:size])) {
+			t.Fatalf("FAILED: AES-SIV-512 does not match the reference for %d bytes", size)
+		}
+	}
+
+	for _, size := range []int{16, 63, 65} {
+		if _, err := NewSIV(make([]byte, size)); err == nil {
+			t.Fatalf("FAILED: %d byte SIV key accepted", size)
+		}
+	}
+
+	if _, err := s.Encrypt(plainText, make([][]byte, sivMaxAuthData+1)...); err == nil {
+		t.Fatalf("FAILED: SIV accepted too many components")
+	}
+
+	if _, err := s.Decrypt(make([]byte, consts.BLOCK_SIZE-1)); err == nil {
+		t.Fatalf("FAILED: SIV accepted a truncated cipherText")
+	}
+}
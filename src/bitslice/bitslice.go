@@ -0,0 +1,402 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package bitslice implements a constant time AES core which evaluates
+// the cipher as a boolean circuit over bitsliced state.
+//
+// Up to four blocks are processed at once. The state is kept in eight
+// 64 bit words, one for every bit of a byte. Inside each word the bits
+// are ordered by row, then column, then block, so every row of the
+// four blocks occupies 16 consecutive bits. There are no branches nor
+// memory accesses depending on the key or the data.
+//
+// The S-box circuit comes from Boyar and Peralta:
+// https://eprint.iacr.org/2011/332.pdf
+package bitslice
+
+import "github.com/wedkarz02/aes256go/src/consts"
+
+// Number of blocks processed in parallel.
+const LANES = 4
+
+// Bitsliced state, word i holds bit i of every byte.
+type State [8]uint64
+
+// Pos returns the bit position of byte i of the given block.
+func pos(block int, i int) uint {
+	row := i % 4
+	col := i / 4
+
+	return uint((row*4+col)*LANES + block)
+}
+
+// Load packs up to LANES consecutive blocks from src into st.
+// Missing blocks are filled with zeros.
+func Load(st *State, src []byte) {
+	*st = State{}
+
+	for n := 0; n < len(src)/consts.BLOCK_SIZE; n++ {
+		for i := 0; i < consts.BLOCK_SIZE; i++ {
+			x := src[n*consts.BLOCK_SIZE+i]
+			p := pos(n, i)
+
+			for b := 0; b < 8; b++ {
+				st[b] |= uint64(x>>b&1) << p
+			}
+		}
+	}
+}
+
+// Store unpacks as many blocks from st as fit in dst.
+func Store(st *State, dst []byte) {
+	for n := 0; n < len(dst)/consts.BLOCK_SIZE; n++ {
+		for i := 0; i < consts.BLOCK_SIZE; i++ {
+			var x byte
+			p := pos(n, i)
+
+			for b := 0; b < 8; b++ {
+				x |= byte(st[b]>>p&1) << b
+			}
+
+			dst[n*consts.BLOCK_SIZE+i] = x
+		}
+	}
+}
+
+// RoundKeys converts an expanded key to bitsliced round keys
+// with every round key copied to all of the lanes.
+func RoundKeys(xKey []byte) []State {
+	roundKeys := make([]State, len(xKey)/consts.BLOCK_SIZE)
+	broadcast := make([]byte, LANES*consts.BLOCK_SIZE)
+
+	for r := range roundKeys {
+		for n := 0; n < LANES; n++ {
+			copy(broadcast[n*consts.BLOCK_SIZE:], xKey[r*consts.BLOCK_SIZE:(r+1)*consts.BLOCK_SIZE])
+		}
+
+		Load(&roundKeys[r], broadcast)
+	}
+
+	for i := range broadcast {
+		broadcast[i] = 0x00
+	}
+
+	return roundKeys
+}
+
+// Encrypt encrypts up to LANES blocks from src into dst.
+func Encrypt(roundKeys []State, dst, src []byte) {
+	var st State
+	Load(&st, src)
+
+	nr := len(roundKeys) - 1
+	AddRoundKey(&st, &roundKeys[0])
+
+	for r := 1; r < nr; r++ {
+		SubBytes(&st)
+		ShiftRows(&st)
+		MixColumns(&st)
+		AddRoundKey(&st, &roundKeys[r])
+	}
+
+	SubBytes(&st)
+	ShiftRows(&st)
+	AddRoundKey(&st, &roundKeys[nr])
+
+	Store(&st, dst)
+}
+
+// Decrypt decrypts up to LANES blocks from src into dst.
+func Decrypt(roundKeys []State, dst, src []byte) {
+	var st State
+	Load(&st, src)
+
+	nr := len(roundKeys) - 1
+	AddRoundKey(&st, &roundKeys[nr])
+
+	for r := nr - 1; r > 0; r-- {
+		InvShiftRows(&st)
+		InvSubBytes(&st)
+		AddRoundKey(&st, &roundKeys[r])
+		InvMixColumns(&st)
+	}
+
+	InvShiftRows(&st)
+	InvSubBytes(&st)
+	AddRoundKey(&st, &roundKeys[0])
+
+	Store(&st, dst)
+}
+
+func AddRoundKey(st *State, roundKey *State) {
+	for b := range st {
+		st[b] ^= roundKey[b]
+	}
+}
+
+// SubBytes evaluates the 113 gate S-box circuit.
+func SubBytes(st *State) {
+	x0, x1, x2, x3 := st[7], st[6], st[5], st[4]
+	x4, x5, x6, x7 := st[3], st[2], st[1], st[0]
+
+	// Top linear transformation.
+	y14 := x3 ^ x5
+	y13 := x0 ^ x6
+	y9 := x0 ^ x3
+	y8 := x0 ^ x5
+	t0 := x1 ^ x2
+	y1 := t0 ^ x7
+	y4 := y1 ^ x3
+	y12 := y13 ^ y14
+	y2 := y1 ^ x0
+	y5 := y1 ^ x6
+	y3 := y5 ^ y8
+	t1 := x4 ^ y12
+	y15 := t1 ^ x5
+	y20 := t1 ^ x1
+	y6 := y15 ^ x7
+	y10 := y15 ^ t0
+	y11 := y20 ^ y9
+	y7 := x7 ^ y11
+	y17 := y10 ^ y11
+	y19 := y10 ^ y8
+	y16 := t0 ^ y11
+	y21 := y13 ^ y16
+	y18 := x0 ^ y16
+
+	// Non-linear section.
+	t2 := y12 & y15
+	t3 := y3 & y6
+	t4 := t3 ^ t2
+	t5 := y4 & x7
+	t6 := t5 ^ t2
+	t7 := y13 & y16
+	t8 := y5 & y1
+	t9 := t8 ^ t7
+	t10 := y2 & y7
+	t11 := t10 ^ t7
+	t12 := y9 & y11
+	t13 := y14 & y17
+	t14 := t13 ^ t12
+	t15 := y8 & y10
+	t16 := t15 ^ t12
+	t17 := t4 ^ t14
+	t18 := t6 ^ t16
+	t19 := t9 ^ t14
+	t20 := t11 ^ t16
+	t21 := t17 ^ y20
+	t22 := t18 ^ y19
+	t23 := t19 ^ y21
+	t24 := t20 ^ y18
+
+	t25 := t21 ^ t22
+	t26 := t21 & t23
+	t27 := t24 ^ t26
+	t28 := t25 & t27
+	t29 := t28 ^ t22
+	t30 := t23 ^ t24
+	t31 := t22 ^ t26
+	t32 := t31 & t30
+	t33 := t32 ^ t24
+	t34 := t23 ^ t33
+	t35 := t27 ^ t33
+	t36 := t24 & t35
+	t37 := t36 ^ t34
+	t38 := t27 ^ t36
+	t39 := t29 & t38
+	t40 := t25 ^ t39
+
+	t41 := t40 ^ t37
+	t42 := t29 ^ t33
+	t43 := t29 ^ t40
+	t44 := t33 ^ t37
+	t45 := t42 ^ t41
+	z0 := t44 & y15
+	z1 := t37 & y6
+	z2 := t33 & x7
+	z3 := t43 & y16
+	z4 := t40 & y1
+	z5 := t29 & y7
+	z6 := t42 & y11
+	z7 := t45 & y17
+	z8 := t41 & y10
+	z9 := t44 & y12
+	z10 := t37 & y3
+	z11 := t33 & y4
+	z12 := t43 & y13
+	z13 := t40 & y5
+	z14 := t29 & y2
+	z15 := t42 & y9
+	z16 := t45 & y14
+	z17 := t41 & y8
+
+	// Bottom linear transformation.
+	t46 := z15 ^ z16
+	t47 := z10 ^ z11
+	t48 := z5 ^ z13
+	t49 := z9 ^ z10
+	t50 := z2 ^ z12
+	t51 := z2 ^ z5
+	t52 := z7 ^ z8
+	t53 := z0 ^ z3
+	t54 := z6 ^ z7
+	t55 := z16 ^ z17
+	t56 := z12 ^ t48
+	t57 := t50 ^ t53
+	t58 := z4 ^ t46
+	t59 := z3 ^ t54
+	t60 := t46 ^ t57
+	t61 := z14 ^ t57
+	t62 := t52 ^ t58
+	t63 := t49 ^ t58
+	t64 := z4 ^ t59
+	t65 := t61 ^ t62
+	t66 := z1 ^ t63
+	s0 := t59 ^ t63
+	s6 := t56 ^ ^t62
+	s7 := t48 ^ ^t60
+	t67 := t64 ^ t65
+	s3 := t53 ^ t66
+	s4 := t51 ^ t66
+	s5 := t47 ^ t65
+	s1 := t64 ^ ^s3
+	s2 := t55 ^ ^t67
+
+	st[7], st[6], st[5], st[4] = s0, s1, s2, s3
+	st[3], st[2], st[1], st[0] = s4, s5, s6, s7
+}
+
+// InvSubBytes reuses the S-box circuit. The inverse S-box
+// is the field inversion wrapped in the inverse affine
+// transformation, and the S-box is the field inversion
+// followed by the affine transformation, so applying the
+// inverse affine transformation on both sides of the
+// S-box leaves only the inversion.
+func InvSubBytes(st *State) {
+	invAffine(st)
+	SubBytes(st)
+	invAffine(st)
+}
+
+// InvAffine undoes the affine transformation of the S-box
+// (including the 0x63 constant).
+func invAffine(st *State) {
+	q0, q1, q2, q3 := ^st[0], ^st[1], st[2], st[3]
+	q4, q5, q6, q7 := st[4], ^st[5], ^st[6], st[7]
+
+	st[0] = q2 ^ q5 ^ q7
+	st[1] = q3 ^ q6 ^ q0
+	st[2] = q4 ^ q7 ^ q1
+	st[3] = q5 ^ q0 ^ q2
+	st[4] = q6 ^ q1 ^ q3
+	st[5] = q7 ^ q2 ^ q4
+	st[6] = q0 ^ q3 ^ q5
+	st[7] = q1 ^ q4 ^ q6
+}
+
+// Every row is rotated right by a multiple of 4 bits,
+// so whole columns of all the blocks move at once.
+func ShiftRows(st *State) {
+	for b := range st {
+		x := st[b]
+		r1 := x >> 16 & 0xffff
+		r2 := x >> 32 & 0xffff
+		r3 := x >> 48
+
+		st[b] = x&0xffff |
+			(r1>>4|r1<<12)&0xffff<<16 |
+			(r2>>8|r2<<8)&0xffff<<32 |
+			(r3>>12|r3<<4)&0xffff<<48
+	}
+}
+
+func InvShiftRows(st *State) {
+	for b := range st {
+		x := st[b]
+		r1 := x >> 16 & 0xffff
+		r2 := x >> 32 & 0xffff
+		r3 := x >> 48
+
+		st[b] = x&0xffff |
+			(r1<<4|r1>>12)&0xffff<<16 |
+			(r2<<8|r2>>8)&0xffff<<32 |
+			(r3<<12|r3>>4)&0xffff<<48
+	}
+}
+
+// Rotating a word by 16 bits moves row i+1 in place of row i,
+// which lets MixColumns combine the rows of every column
+// with plain word operations.
+func rotRows(x uint64, n uint) uint64 {
+	return x>>(16*n) | x<<(64-16*n)
+}
+
+// Xtime multiplies every byte of the state by 2 in GF(2^8).
+func xtime(st *State) State {
+	return State{
+		st[7],
+		st[0] ^ st[7],
+		st[1],
+		st[2] ^ st[7],
+		st[3] ^ st[7],
+		st[4],
+		st[5],
+		st[6],
+	}
+}
+
+// MixColumns computes 2*a0 ^ 3*a1 ^ a2 ^ a3 for every row
+// as 2*(a0 ^ a1) ^ a1 ^ a2 ^ a3.
+func MixColumns(st *State) {
+	var sum State
+
+	for b := range st {
+		sum[b] = st[b] ^ rotRows(st[b], 1)
+	}
+
+	dbl := xtime(&sum)
+
+	for b := range st {
+		r1 := rotRows(st[b], 1)
+		st[b] = dbl[b] ^ r1 ^ rotRows(st[b]^r1, 2)
+	}
+}
+
+// InvMixColumns is MixColumns preceded by a multiplication
+// of the matrix by {04}x^2 + {05}, which only needs two
+// doublings.
+//
+// https://link.springer.com/book/10.1007/978-3-662-04722-4 (section 4.1.3)
+func InvMixColumns(st *State) {
+	var sum State
+
+	for b := range st {
+		sum[b] = st[b] ^ rotRows(st[b], 2)
+	}
+
+	sum = xtime(&sum)
+	sum = xtime(&sum)
+
+	for b := range st {
+		st[b] ^= sum[b]
+	}
+
+	MixColumns(st)
+}
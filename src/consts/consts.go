@@ -28,22 +28,40 @@ const (
 	// Size of the AES key in the 256 bit variant.
 	KEY_SIZE = 32
 
+	// Size of the AES key in the 128 bit variant.
+	KEY_SIZE_128 = 16
+
+	// Size of the AES key in the 192 bit variant.
+	KEY_SIZE_192 = 24
+
+	// Size of the AES key in the 256 bit variant.
+	KEY_SIZE_256 = KEY_SIZE
+
 	// Size of the key segments used in key expansion.
 	WORD_SIZE = 4
 
-	// Number of words in the key.
+	// Number of words in the key in the 256 bit variant.
 	NK = 8
 
-	// Number of AES rounds.
+	// Number of AES rounds in the 256 bit variant.
 	NR = 14
 
+	// Number of AES rounds in the 128 bit variant.
+	NR_128 = 10
+
+	// Number of AES rounds in the 192 bit variant.
+	NR_192 = 12
+
+	// Number of AES rounds in the 256 bit variant.
+	NR_256 = NR
+
 	// Number of words in key expansion block.
 	NB = 4
 
-	// Number of derived keys needed.
+	// Number of derived keys needed in the 256 bit variant.
 	ROUND_KEYS = NR + 1
 
-	// Total size of the expanded key.
+	// Total size of the expanded key in the 256 bit variant.
 	EXP_KEY_SIZE = BLOCK_SIZE * ROUND_KEYS
 
 	// Size of the initializing vector.
@@ -22,22 +22,123 @@
 package counter
 
 import (
+	"encoding/binary"
+	"errors"
+	"math"
+
 	"github.com/wedkarz02/aes256go/src/consts"
 )
 
+// ErrOverflow is returned when the counter wraps around,
+// which would make the keystream repeat.
+var ErrOverflow = errors.New("counter overflow")
+
+// Counter is a full counter block. Only the last width bytes
+// are incremented, the leading bytes hold the nonce.
+//
+// https://nvlpubs.nist.gov/nistpubs/Legacy/SP/nistspecialpublication800-38a.pdf (Appendix B)
 type Counter struct {
-	Bytes [consts.COUNTER_SIZE]byte
+	Block    [consts.BLOCK_SIZE]byte
+	width    int
+	overflow bool
 }
 
+// NewCounter returns a zero counter block with
+// a COUNTER_SIZE byte counter.
 func NewCounter() *Counter {
-	return &Counter{}
+	return &Counter{width: consts.COUNTER_SIZE}
+}
+
+// NewNonceCounter returns a counter block starting with the nonce,
+// followed by a counter of BLOCK_SIZE - len(nonce) bytes set to 0.
+func NewNonceCounter(nonce []byte) (*Counter, error) {
+	if len(nonce) >= consts.BLOCK_SIZE {
+		return nil, errors.New("invalid nonce size")
+	}
+
+	c := &Counter{width: consts.BLOCK_SIZE - len(nonce)}
+	copy(c.Block[:], nonce)
+
+	return c, nil
+}
+
+// NewCounterBlock returns a counter starting at the given block,
+// of which the last width bytes are incremented.
+func NewCounterBlock(block []byte, width int) (*Counter, error) {
+	if len(block) != consts.BLOCK_SIZE {
+		return nil, errors.New("invalid counter block size")
+	}
+
+	if width < 1 || width > consts.BLOCK_SIZE {
+		return nil, errors.New("invalid counter width")
+	}
+
+	c := &Counter{width: width}
+	copy(c.Block[:], block)
+
+	return c, nil
+}
+
+// Bytes returns the counter part of the block, the last Width bytes.
+// It replaces the former Bytes field, so the returned slice shares
+// its storage with the block and writing to it sets the counter.
+func (c *Counter) Bytes() []byte {
+	return c.Block[consts.BLOCK_SIZE-c.width:]
+}
+
+// Width returns the number of bytes incremented.
+func (c *Counter) Width() int {
+	return c.width
 }
 
-func (c *Counter) Increment() {
-	for i := consts.COUNTER_SIZE - 1; i >= 0; i-- {
-		c.Bytes[i]++
-		if c.Bytes[i] != 0 {
-			break
+// Increment moves the counter to the next block. ErrOverflow is
+// returned once the counter wraps around, the block must not
+// be used afterwards.
+func (c *Counter) Increment() error {
+	if c.overflow {
+		return ErrOverflow
+	}
+
+	for i := consts.BLOCK_SIZE - 1; i >= consts.BLOCK_SIZE-c.width; i-- {
+		c.Block[i]++
+		if c.Block[i] != 0 {
+			return nil
+		}
+	}
+
+	c.overflow = true
+	return ErrOverflow
+}
+
+// Remaining returns the number of blocks that can still be used,
+// including the current one, before the counter wraps around.
+// It saturates at math.MaxUint64 for wide counters.
+func (c *Counter) Remaining() uint64 {
+	if c.overflow {
+		return 0
+	}
+
+	ctr := c.Block[consts.BLOCK_SIZE-c.width:]
+
+	if c.width < 8 {
+		var v uint64
+		for _, b := range ctr {
+			v = v<<8 | uint64(b)
 		}
+
+		return 1<<(8*uint(c.width)) - v
 	}
+
+	for _, b := range ctr[:c.width-8] {
+		if b != 0xff {
+			return math.MaxUint64
+		}
+	}
+
+	low := binary.BigEndian.Uint64(ctr[c.width-8:])
+	if low == 0 {
+		return math.MaxUint64
+	}
+
+	return -low
 }
@@ -21,7 +21,11 @@
 // Package galois implements Galois Finite Field arithmetic used in AES.
 package galois
 
-import "github.com/wedkarz02/aes256go/src/consts"
+import (
+	"encoding/binary"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+)
 
 func Gadd(a byte, b byte) byte {
 	return a ^ b
@@ -62,22 +66,39 @@ func GxorBlocks(a []byte, b []byte) []byte {
 	return result
 }
 
+// GmulBlocks multiplies two blocks in GF(2^128) defined by
+// the polynomial x^128 + x^7 + x^2 + x + 1, using the bit order
+// from the GCM specification (the first bit of a block is the
+// coefficient of x^0). There are no branches depending on
+// the data, so it runs in constant time.
+//
+// https://nvlpubs.nist.gov/nistpubs/Legacy/SP/nistspecialpublication800-38d.pdf (Algorithm 1)
 func GmulBlocks(x []byte, y []byte) []byte {
-	prod := make([]byte, consts.BLOCK_SIZE)
-
-	for i := 0; i < 16; i++ {
-		for j := 0; j < 8; j++ {
-			if (y[i]>>uint(j))&1 == 1 {
-				for k := 0; k < 16; k++ {
-					prod[k] = Gadd(prod[k], x[(i+k)%16])
-				}
-			}
-		}
+	var z0, z1 uint64
+	v0 := binary.BigEndian.Uint64(y[:8])
+	v1 := binary.BigEndian.Uint64(y[8:consts.BLOCK_SIZE])
+
+	for i := 0; i < 8*consts.BLOCK_SIZE; i++ {
+		mask := -uint64(x[i/8] >> (7 - i%8) & 1)
+		z0 ^= v0 & mask
+		z1 ^= v1 & mask
+
+		// V * x, reduced by R = 11100001 || 0^120 when
+		// the coefficient of x^127 is shifted out.
+		reduce := -(v1 & 1)
+		v1 = v1>>1 | v0<<63
+		v0 = v0>>1 ^ 0xe1<<56&reduce
 	}
 
+	prod := make([]byte, consts.BLOCK_SIZE)
+	binary.BigEndian.PutUint64(prod[:8], z0)
+	binary.BigEndian.PutUint64(prod[8:], z1)
+
 	return prod
 }
 
+// Ghash computes the GHASH function of GCM. The input
+// has to be a multiple of the block size.
 func Ghash(x []byte, h []byte) []byte {
 	hash := make([]byte, consts.BLOCK_SIZE)
 
@@ -33,7 +33,21 @@ import (
 	"github.com/wedkarz02/aes256go/src/sbox"
 )
 
-type ExpandedKey [consts.EXP_KEY_SIZE]byte
+type ExpandedKey []byte
+
+// Rounds returns the number of AES rounds for the given key size.
+func Rounds(keySize int) (int, error) {
+	switch keySize {
+	case consts.KEY_SIZE_128:
+		return consts.NR_128, nil
+	case consts.KEY_SIZE_192:
+		return consts.NR_192, nil
+	case consts.KEY_SIZE_256:
+		return consts.NR_256, nil
+	}
+
+	return 0, errors.New("invalid key size")
+}
 
 func Rcon(idx byte) byte {
 	if idx == 0 {
@@ -65,7 +79,9 @@ func RotWord(word [consts.WORD_SIZE]byte) ([consts.WORD_SIZE]byte, error) {
 	return rotated, nil
 }
 
-func SubWord(word [consts.WORD_SIZE]byte, sbox *sbox.SBOX) ([consts.WORD_SIZE]byte, error) {
+// SubWord substitutes every byte of the word in constant time,
+// so the key schedule does not leak the key through the cache.
+func SubWord(word [consts.WORD_SIZE]byte, sb *sbox.SBOX) ([consts.WORD_SIZE]byte, error) {
 	if len(word) != consts.WORD_SIZE {
 		return [consts.WORD_SIZE]byte{}, errors.New("invalid round key word size")
 	}
@@ -73,7 +89,7 @@ func SubWord(word [consts.WORD_SIZE]byte, sbox *sbox.SBOX) ([consts.WORD_SIZE]by
 	var subw [consts.WORD_SIZE]byte
 
 	for i := 0; i < consts.WORD_SIZE; i++ {
-		subw[i] = sbox[word[i]]
+		subw[i] = sbox.SubByteCT(sb, word[i])
 	}
 
 	return subw, nil
@@ -102,27 +118,28 @@ func ScheduleCore(word [consts.WORD_SIZE]byte, idx byte) ([consts.WORD_SIZE]byte
 	return word, nil
 }
 
-func ExpandKey(k []byte) (*ExpandedKey, error) {
-	if len(k) != consts.KEY_SIZE {
-		return nil, errors.New("invalid key size")
+func ExpandKey(k []byte) (ExpandedKey, error) {
+	nr, err := Rounds(len(k))
+
+	if err != nil {
+		return nil, err
 	}
 
-	var xKey ExpandedKey
-	copy(xKey[:], k)
+	xKey := make(ExpandedKey, consts.BLOCK_SIZE*(nr+1))
+	copy(xKey, k)
 
 	sbox := sbox.InitSBOX()
 	var tmpKey [consts.WORD_SIZE]byte
-	var c byte = consts.KEY_SIZE
+	var c = len(k)
 	var idx byte = 1
-	var a byte
-	var err error
+	var a int
 
-	for c < consts.EXP_KEY_SIZE {
+	for c < len(xKey) {
 		for a = 0; a < consts.WORD_SIZE; a++ {
 			tmpKey[a] = xKey[a+c-consts.WORD_SIZE]
 		}
 
-		if c%consts.KEY_SIZE == 0 {
+		if c%len(k) == 0 {
 			tmpKey, err = ScheduleCore(tmpKey, idx)
 			idx++
 
@@ -131,7 +148,9 @@ func ExpandKey(k []byte) (*ExpandedKey, error) {
 			}
 		}
 
-		if c%consts.KEY_SIZE == consts.BLOCK_SIZE {
+		// Only the 256 bit variant substitutes the word
+		// in the middle of every key sized chunk.
+		if len(k) == consts.KEY_SIZE_256 && c%len(k) == consts.BLOCK_SIZE {
 			tmpKey, err = SubWord(tmpKey, sbox)
 
 			if err != nil {
@@ -140,10 +159,10 @@ func ExpandKey(k []byte) (*ExpandedKey, error) {
 		}
 
 		for a = 0; a < consts.WORD_SIZE; a++ {
-			xKey[c] = xKey[c-consts.KEY_SIZE] ^ tmpKey[a]
+			xKey[c] = xKey[c-len(k)] ^ tmpKey[a]
 			c++
 		}
 	}
 
-	return &xKey, nil
+	return xKey, nil
 }
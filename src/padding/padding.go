@@ -28,10 +28,19 @@
 // modes of operation.
 package padding
 
-import "github.com/wedkarz02/aes256go/src/consts"
+import (
+	"crypto/subtle"
+	"errors"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+)
 
 type Pad func([]byte) []byte
-type UnPad func([]byte) []byte
+
+// UnPad removes the padding from the decrypted data. It returns
+// an error if the padding is malformed, which usually means
+// that the cipherText was corrupted or tampered with.
+type UnPad func([]byte) ([]byte, error)
 
 func ZeroPadding(data []byte) []byte {
 	paddedData := make([]byte, len(data))
@@ -47,15 +56,15 @@ func ZeroPadding(data []byte) []byte {
 	return paddedData
 }
 
-func ZeroUnpadding(paddedData []byte) []byte {
-	for paddedData[len(paddedData)-1] == 0x00 {
+func ZeroUnpadding(paddedData []byte) ([]byte, error) {
+	for len(paddedData) > 0 && paddedData[len(paddedData)-1] == 0x00 {
 		paddedData = paddedData[:len(paddedData)-1]
 	}
 
 	data := make([]byte, len(paddedData))
 	copy(data, paddedData)
 
-	return data
+	return data, nil
 }
 
 func PKCS7Padding(data []byte) []byte {
@@ -72,11 +81,33 @@ func PKCS7Padding(data []byte) []byte {
 	return paddedData
 }
 
-func PKCS7Unpadding(paddedData []byte) []byte {
+// PKCS7Unpadding verifies and removes PKCS#7 padding. The padding
+// length has to be between 1 and the block size, and every padding
+// byte has to be equal to it. The bytes are checked in constant time
+// to avoid leaking which check failed.
+func PKCS7Unpadding(paddedData []byte) ([]byte, error) {
+	if len(paddedData) == 0 || len(paddedData)%consts.BLOCK_SIZE != 0 {
+		return nil, errors.New("invalid padded data size")
+	}
+
 	padLength := paddedData[len(paddedData)-1]
 
+	ok := subtle.ConstantTimeLessOrEq(1, int(padLength))
+	ok &= subtle.ConstantTimeLessOrEq(int(padLength), consts.BLOCK_SIZE)
+
+	lastBlock := paddedData[len(paddedData)-consts.BLOCK_SIZE:]
+	for i, b := range lastBlock {
+		// Only the last padLength bytes belong to the padding.
+		inPad := subtle.ConstantTimeLessOrEq(consts.BLOCK_SIZE-int(padLength), i)
+		ok &= subtle.ConstantTimeByteEq(b, padLength) | (inPad ^ 1)
+	}
+
+	if ok != 1 {
+		return nil, errors.New("invalid padding")
+	}
+
 	data := make([]byte, len(paddedData)-int(padLength))
 	copy(data, paddedData[:len(paddedData)-int(padLength)])
 
-	return data
+	return data, nil
 }
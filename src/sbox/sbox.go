@@ -21,6 +21,8 @@
 // Package sbox implements AES lookup tables used in SubBytes step.
 package sbox
 
+import "crypto/subtle"
+
 type SBOX [256]byte
 
 func RotL8(x byte, shift byte) byte {
@@ -70,3 +72,17 @@ func InitInvSBOX(sbox *SBOX) *SBOX {
 
 	return invsbox
 }
+
+// SubByteCT returns sbox[x] without indexing the table with x.
+// Every entry is read, so the memory access pattern does not
+// depend on the (possibly secret) input.
+func SubByteCT(sbox *SBOX, x byte) byte {
+	var sub byte
+
+	for i := range sbox {
+		mask := -byte(subtle.ConstantTimeByteEq(byte(i), x))
+		sub |= sbox[i] & mask
+	}
+
+	return sub
+}
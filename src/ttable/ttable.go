@@ -0,0 +1,83 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package ttable implements precomputed lookup tables combining
+// SubBytes, ShiftRows and MixColumns into 32 bit word operations.
+//
+// The tables are generated once at package initialization
+// from the sbox package.
+package ttable
+
+import (
+	"math/bits"
+
+	"github.com/wedkarz02/aes256go/src/galois"
+	"github.com/wedkarz02/aes256go/src/sbox"
+)
+
+var (
+	// Substitution tables.
+	SBOX    *sbox.SBOX
+	InvSBOX *sbox.SBOX
+
+	// Encryption tables.
+	Te0 [256]uint32
+	Te1 [256]uint32
+	Te2 [256]uint32
+	Te3 [256]uint32
+
+	// Decryption tables.
+	Td0 [256]uint32
+	Td1 [256]uint32
+	Td2 [256]uint32
+	Td3 [256]uint32
+)
+
+func init() {
+	SBOX = sbox.InitSBOX()
+	InvSBOX = sbox.InitInvSBOX(SBOX)
+
+	for i := 0; i < 256; i++ {
+		s := SBOX[i]
+		te := Word(galois.Gmul(s, 0x02), s, s, galois.Gmul(s, 0x03))
+
+		Te0[i] = te
+		Te1[i] = bits.RotateLeft32(te, -8)
+		Te2[i] = bits.RotateLeft32(te, -16)
+		Te3[i] = bits.RotateLeft32(te, -24)
+
+		s = InvSBOX[i]
+		td := Word(galois.Gmul(s, 0x0e), galois.Gmul(s, 0x09), galois.Gmul(s, 0x0d), galois.Gmul(s, 0x0b))
+
+		Td0[i] = td
+		Td1[i] = bits.RotateLeft32(td, -8)
+		Td2[i] = bits.RotateLeft32(td, -16)
+		Td3[i] = bits.RotateLeft32(td, -24)
+	}
+}
+
+func Word(b0 byte, b1 byte, b2 byte, b3 byte) uint32 {
+	return uint32(b0)<<24 | uint32(b1)<<16 | uint32(b2)<<8 | uint32(b3)
+}
+
+// InvMixWord applies InvMixColumns to a single column.
+func InvMixWord(w uint32) uint32 {
+	return Td0[SBOX[w>>24]] ^ Td1[SBOX[w>>16&0xff]] ^ Td2[SBOX[w>>8&0xff]] ^ Td3[SBOX[w&0xff]]
+}
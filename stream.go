@@ -0,0 +1,371 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"crypto/cipher"
+	"errors"
+	"io"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+	"github.com/wedkarz02/aes256go/src/counter"
+)
+
+// StreamMode selects the mode of operation used by
+// NewEncryptWriter and NewDecryptReader.
+type StreamMode int
+
+const (
+	// StreamCTR uses CTR mode with a 12 byte nonce header,
+	// compatible with EncryptCTR and DecryptCTR.
+	StreamCTR StreamMode = iota
+
+	// StreamOFB uses OFB mode with a 16 byte IV header,
+	// compatible with EncryptOFB and DecryptOFB.
+	StreamOFB
+
+	// StreamCFB uses CFB mode with full block segments and
+	// a 16 byte IV header, compatible with EncryptCFB and
+	// DecryptCFB called with s = 16.
+	StreamCFB
+)
+
+// CtrStream implements cipher.Stream in counter mode.
+type ctrStream struct {
+	cipher    *AES256
+	ctr       *counter.Counter
+	ctrBlocks []byte
+	keyStream []byte
+	pos       int
+}
+
+// OfbStream implements cipher.Stream in output feedback mode.
+type ofbStream struct {
+	cipher    *AES256
+	keyStream []byte
+	pos       int
+}
+
+// CfbStream implements cipher.Stream in cipher feedback mode
+// with a segment size of s bytes.
+type cfbStream struct {
+	cipher    *AES256
+	shiftReg  []byte
+	keyStream []byte
+	segment   []byte
+	s         int
+	pos       int
+	decrypt   bool
+}
+
+// NewCTRStream returns a cipher.Stream encrypting or decrypting
+// in CTR mode with the given 12 byte nonce and the counter
+// starting at 0, like EncryptCTRWithNonce and DecryptCTR.
+//
+// The keystream state is kept across calls, so the data can
+// be processed in chunks of any size. XORKeyStream panics once
+// the 32 bit counter runs out.
+//
+// https://en.wikipedia.org/wiki/Block_cipher_mode_of_operation#Counter_(CTR)
+func (a *AES256) NewCTRStream(nonce []byte) (cipher.Stream, error) {
+	if len(nonce) != consts.NONCE_SIZE {
+		return nil, errors.New("invalid nonce size")
+	}
+
+	ctr, err := counter.NewNonceCounter(nonce)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newCTRStream(a, ctr), nil
+}
+
+// NewCTRStreamWithCounter returns a cipher.Stream encrypting or
+// decrypting in CTR mode starting at the given 16 byte counter block,
+// of which the last width bytes are incremented, like
+// EncryptCTRWithCounter and DecryptCTRWithCounter.
+//
+// XORKeyStream panics if the counter runs out.
+//
+// https://nvlpubs.nist.gov/nistpubs/Legacy/SP/nistspecialpublication800-38a.pdf (Appendix B)
+func (a *AES256) NewCTRStreamWithCounter(counterBlock []byte, width int) (cipher.Stream, error) {
+	ctr, err := counter.NewCounterBlock(counterBlock, width)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newCTRStream(a, ctr), nil
+}
+
+// NewOFBStream returns a cipher.Stream encrypting or decrypting
+// in OFB mode with the given IV, like EncryptOFBWithIV and DecryptOFB.
+//
+// https://en.wikipedia.org/wiki/Block_cipher_mode_of_operation#Output_feedback_(OFB)
+func (a *AES256) NewOFBStream(iv []byte) (cipher.Stream, error) {
+	if len(iv) != consts.IV_SIZE {
+		return nil, errors.New("invalid iv size")
+	}
+
+	keyStream := make([]byte, consts.BLOCK_SIZE)
+	copy(keyStream, iv)
+
+	return &ofbStream{cipher: a, keyStream: keyStream, pos: consts.BLOCK_SIZE}, nil
+}
+
+// NewCFBEncryptStream returns a cipher.Stream encrypting in CFB
+// mode with the given IV and segment size, like EncryptCFBWithIV.
+//
+// 1 <= s <= 16 (block size)
+//
+// https://en.wikipedia.org/wiki/Block_cipher_mode_of_operation#Cipher_feedback_(CFB)
+func (a *AES256) NewCFBEncryptStream(iv []byte, s int) (cipher.Stream, error) {
+	return newCFBStream(a, iv, s, false)
+}
+
+// NewCFBDecryptStream returns a cipher.Stream decrypting in CFB
+// mode with the given IV and segment size, like DecryptCFB.
+//
+// 1 <= s <= 16 (block size)
+//
+// https://en.wikipedia.org/wiki/Block_cipher_mode_of_operation#Cipher_feedback_(CFB)
+func (a *AES256) NewCFBDecryptStream(iv []byte, s int) (cipher.Stream, error) {
+	return newCFBStream(a, iv, s, true)
+}
+
+// NewEncryptWriter generates a random IV (or nonce), writes it to w
+// and returns a writer encrypting everything written to it into w.
+//
+// The output is the same as the one of the corresponding one-shot
+// encryption function, so it can be decrypted with either of them.
+func (a *AES256) NewEncryptWriter(w io.Writer, mode StreamMode) (io.Writer, error) {
+	iv := make([]byte, a.headerSize(mode))
+	if err := readRandom(a.rand, iv, "iv"); err != nil {
+		return nil, err
+	}
+
+	stream, err := a.newModeStream(mode, iv, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(iv); err != nil {
+		return nil, err
+	}
+
+	return &cipher.StreamWriter{S: stream, W: w}, nil
+}
+
+// NewDecryptReader reads the IV (or nonce) from r and returns
+// a reader decrypting the rest of r.
+func (a *AES256) NewDecryptReader(r io.Reader, mode StreamMode) (io.Reader, error) {
+	iv := make([]byte, a.headerSize(mode))
+	if _, err := io.ReadFull(r, iv); err != nil {
+		return nil, errors.New("cipherText too short: missing iv")
+	}
+
+	stream, err := a.newModeStream(mode, iv, true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &cipher.StreamReader{S: stream, R: r}, nil
+}
+
+// HeaderSize returns the size of the IV (or nonce)
+// prepended to the cipherText in the given mode.
+func (a *AES256) headerSize(mode StreamMode) int {
+	if mode == StreamCTR {
+		return consts.NONCE_SIZE
+	}
+
+	return consts.IV_SIZE
+}
+
+// NewModeStream returns the stream for the given mode.
+func (a *AES256) newModeStream(mode StreamMode, iv []byte, decrypt bool) (cipher.Stream, error) {
+	switch mode {
+	case StreamCTR:
+		return a.NewCTRStream(iv)
+	case StreamOFB:
+		return a.NewOFBStream(iv)
+	case StreamCFB:
+		return newCFBStream(a, iv, consts.BLOCK_SIZE, decrypt)
+	}
+
+	return nil, errors.New("invalid stream mode")
+}
+
+func newCTRStream(a *AES256, ctr *counter.Counter) *ctrStream {
+	n := ctrBatchSize * consts.BLOCK_SIZE
+
+	return &ctrStream{
+		cipher:    a,
+		ctr:       ctr,
+		ctrBlocks: make([]byte, n),
+		keyStream: make([]byte, 0, n),
+	}
+}
+
+// XORKeyStream XORs each byte in src with a byte from the
+// keystream. Dst and src must overlap entirely or not at all.
+func (x *ctrStream) XORKeyStream(dst, src []byte) {
+	checkStreamBuffers(dst, src)
+
+	for len(src) > 0 {
+		if x.pos == len(x.keyStream) {
+			x.refill(len(src))
+		}
+
+		n := xorBytes(dst, src, x.keyStream[x.pos:])
+		x.pos += n
+		dst, src = dst[n:], src[n:]
+	}
+}
+
+// Refill encrypts the next counter blocks. Counter blocks are
+// independent, so they are encrypted in batches to let the core
+// process several at once, but no more than needed for the
+// remaining size bytes are generated.
+func (x *ctrStream) refill(size int) {
+	n := (size + consts.BLOCK_SIZE - 1) / consts.BLOCK_SIZE * consts.BLOCK_SIZE
+	if n > cap(x.keyStream) {
+		n = cap(x.keyStream)
+	}
+
+	if remaining := x.ctr.Remaining(); remaining == 0 {
+		panic("aes256go: " + counter.ErrOverflow.Error())
+	} else if uint64(n/consts.BLOCK_SIZE) > remaining {
+		n = int(remaining) * consts.BLOCK_SIZE
+	}
+
+	for j := 0; j < n; j += consts.BLOCK_SIZE {
+		copy(x.ctrBlocks[j:], x.ctr.Block[:])
+		x.ctr.Increment()
+	}
+
+	x.keyStream = x.keyStream[:n]
+	x.cipher.encryptBlocks(x.keyStream, x.ctrBlocks[:n])
+	x.pos = 0
+}
+
+// XORKeyStream XORs each byte in src with a byte from the
+// keystream. Dst and src must overlap entirely or not at all.
+func (x *ofbStream) XORKeyStream(dst, src []byte) {
+	checkStreamBuffers(dst, src)
+
+	for len(src) > 0 {
+		if x.pos == consts.BLOCK_SIZE {
+			x.cipher.encryptBlocks(x.keyStream, x.keyStream)
+			x.pos = 0
+		}
+
+		n := xorBytes(dst, src, x.keyStream[x.pos:])
+		x.pos += n
+		dst, src = dst[n:], src[n:]
+	}
+}
+
+func newCFBStream(a *AES256, iv []byte, s int, decrypt bool) (*cfbStream, error) {
+	if s < 1 || s > consts.BLOCK_SIZE {
+		return nil, errors.New("invalid segment size")
+	}
+
+	if len(iv) != consts.IV_SIZE {
+		return nil, errors.New("invalid iv size")
+	}
+
+	shiftReg := make([]byte, consts.BLOCK_SIZE)
+	copy(shiftReg, iv)
+
+	return &cfbStream{
+		cipher:    a,
+		shiftReg:  shiftReg,
+		keyStream: make([]byte, consts.BLOCK_SIZE),
+		segment:   make([]byte, s),
+		s:         s,
+		decrypt:   decrypt,
+	}, nil
+}
+
+// XORKeyStream XORs each byte in src with a byte from the
+// keystream. Dst and src must overlap entirely or not at all.
+//
+// The ciphertext of every segment is fed back into the shift
+// register once the segment is complete, so segments may be
+// split across calls.
+func (x *cfbStream) XORKeyStream(dst, src []byte) {
+	checkStreamBuffers(dst, src)
+
+	for i, b := range src {
+		if x.pos == 0 {
+			x.cipher.encryptBlocks(x.keyStream, x.shiftReg)
+		}
+
+		out := b ^ x.keyStream[x.pos]
+
+		if x.decrypt {
+			x.segment[x.pos] = b
+		} else {
+			x.segment[x.pos] = out
+		}
+
+		dst[i] = out
+		x.pos++
+
+		if x.pos == x.s {
+			copy(x.shiftReg, x.shiftReg[x.s:])
+			copy(x.shiftReg[consts.BLOCK_SIZE-x.s:], x.segment)
+			x.pos = 0
+		}
+	}
+}
+
+// CheckStreamBuffers panics if dst can't hold the output
+// of XORKeyStream, just like the streams of crypto/cipher.
+func checkStreamBuffers(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("aes256go: output smaller than input")
+	}
+
+	if inexactOverlap(dst[:len(src)], src) {
+		panic("aes256go: invalid buffer overlap")
+	}
+}
+
+// XorBytes sets dst[i] = src[i] ^ keyStream[i] for as many
+// bytes as available in both src and keyStream and returns
+// the number of bytes processed.
+func xorBytes(dst, src, keyStream []byte) int {
+	n := len(src)
+	if len(keyStream) < n {
+		n = len(keyStream)
+	}
+
+	for i := 0; i < n; i++ {
+		dst[i] = src[i] ^ keyStream[i]
+	}
+
+	return n
+}
@@ -0,0 +1,260 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+)
+
+// XorChunks runs the stream over src split at random boundaries.
+func xorChunks(stream cipher.Stream, src []byte, rng *rand.Rand) []byte {
+	dst := make([]byte, len(src))
+
+	for i := 0; i < len(src); {
+		n := rng.Intn(3 * consts.BLOCK_SIZE)
+		if i+n > len(src) {
+			n = len(src) - i
+		}
+
+		stream.XORKeyStream(dst[i:i+n], src[i:i+n])
+		i += n
+	}
+
+	return dst
+}
+
+func TestStreamsMatchOneShot(t *testing.T) {
+	a, err := NewAES256FromKey(bytes.Repeat([]byte{0x17}, consts.KEY_SIZE))
+	if err != nil {
+		panic(err)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	iv := []byte("0123456789abcdef")
+	plainText := make([]byte, 1000)
+	rng.Read(plainText)
+
+	for _, size := range []int{0, 1, 15, 16, 17, 127, 128, 129, 1000} {
+		for trial := 0; trial < 8; trial++ {
+			expected, err := a.EncryptCTRWithNonce(iv[:consts.NONCE_SIZE], plainText[:size])
+			if err != nil {
+				panic(err)
+			}
+
+			stream, err := a.NewCTRStream(iv[:consts.NONCE_SIZE])
+			if err != nil {
+				panic(err)
+			}
+
+			if !bytes.Equal(xorChunks(stream, plainText[:size], rng), expected[consts.NONCE_SIZE:]) {
+				t.Fatalf("FAILED: chunked CTR does not match EncryptCTR for %d bytes", size)
+			}
+
+			expected, err = a.EncryptOFBWithIV(iv, plainText[:size])
+			if err != nil {
+				panic(err)
+			}
+
+			stream, err = a.NewOFBStream(iv)
+			if err != nil {
+				panic(err)
+			}
+
+			if !bytes.Equal(xorChunks(stream, plainText[:size], rng), expected[consts.IV_SIZE:]) {
+				t.Fatalf("FAILED: chunked OFB does not match EncryptOFB for %d bytes", size)
+			}
+
+			for _, s := range []int{1, 3, 8, consts.BLOCK_SIZE} {
+				expected, err = a.EncryptCFBWithIV(iv, plainText[:size], s)
+				if err != nil {
+					panic(err)
+				}
+
+				stream, err = a.NewCFBEncryptStream(iv, s)
+				if err != nil {
+					panic(err)
+				}
+
+				if !bytes.Equal(xorChunks(stream, plainText[:size], rng), expected[consts.IV_SIZE:]) {
+					t.Fatalf("FAILED: chunked CFB-%d does not match EncryptCFB for %d bytes", s, size)
+				}
+
+				stream, err = a.NewCFBDecryptStream(iv, s)
+				if err != nil {
+					panic(err)
+				}
+
+				if !bytes.Equal(xorChunks(stream, expected[consts.IV_SIZE:], rng), plainText[:size]) {
+					t.Fatalf("FAILED: chunked CFB-%d decryption failed for %d bytes", s, size)
+				}
+			}
+		}
+	}
+}
+
+func TestStreamsInPlace(t *testing.T) {
+	a, err := NewAES256FromKey(make([]byte, consts.KEY_SIZE))
+	if err != nil {
+		panic(err)
+	}
+
+	iv := make([]byte, consts.IV_SIZE)
+	plainText := bytes.Repeat([]byte("in place"), 9)
+
+	expected, err := a.EncryptCFBWithIV(iv, plainText, 5)
+	if err != nil {
+		panic(err)
+	}
+
+	stream, err := a.NewCFBEncryptStream(iv, 5)
+	if err != nil {
+		panic(err)
+	}
+
+	buf := append([]byte{}, plainText...)
+	stream.XORKeyStream(buf[:7], buf[:7])
+	stream.XORKeyStream(buf[7:], buf[7:])
+
+	if !bytes.Equal(buf, expected[consts.IV_SIZE:]) {
+		t.Fatalf("FAILED: in place CFB mismatch")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("FAILED: short dst did not panic")
+		}
+	}()
+
+	stream.XORKeyStream(buf[:1], buf[:2])
+}
+
+func TestStreamsErrors(t *testing.T) {
+	a, err := NewAES256FromKey(make([]byte, consts.KEY_SIZE))
+	if err != nil {
+		panic(err)
+	}
+
+	if _, err := a.NewCTRStream(make([]byte, consts.NONCE_SIZE+1)); err == nil {
+		t.Fatalf("FAILED: CTR stream accepted a wrong nonce size")
+	}
+
+	if _, err := a.NewOFBStream(make([]byte, consts.IV_SIZE-1)); err == nil {
+		t.Fatalf("FAILED: OFB stream accepted a wrong iv size")
+	}
+
+	if _, err := a.NewCFBEncryptStream(make([]byte, consts.IV_SIZE), 0); err == nil {
+		t.Fatalf("FAILED: CFB stream accepted a wrong segment size")
+	}
+
+	if _, err := a.NewEncryptWriter(io.Discard, StreamMode(42)); err == nil {
+		t.Fatalf("FAILED: invalid stream mode accepted")
+	}
+
+	if _, err := a.NewDecryptReader(bytes.NewReader(make([]byte, 3)), StreamOFB); err == nil {
+		t.Fatalf("FAILED: reader accepted a truncated iv")
+	}
+}
+
+func TestEncryptWriterDecryptReader(t *testing.T) {
+	k := bytes.Repeat([]byte{0x55}, consts.KEY_SIZE)
+	plainText := bytes.Repeat([]byte("multi-gigabyte files, or not quite"), 100)
+
+	for _, test := range []struct {
+		mode    StreamMode
+		encrypt func(*AES256) ([]byte, error)
+		decrypt func(*AES256, []byte) ([]byte, error)
+	}{
+		{
+			mode:    StreamCTR,
+			encrypt: func(a *AES256) ([]byte, error) { return a.EncryptCTR(plainText) },
+			decrypt: func(a *AES256, c []byte) ([]byte, error) { return a.DecryptCTR(c) },
+		},
+		{
+			mode:    StreamOFB,
+			encrypt: func(a *AES256) ([]byte, error) { return a.EncryptOFB(plainText) },
+			decrypt: func(a *AES256, c []byte) ([]byte, error) { return a.DecryptOFB(c) },
+		},
+		{
+			mode:    StreamCFB,
+			encrypt: func(a *AES256) ([]byte, error) { return a.EncryptCFB(plainText, consts.BLOCK_SIZE) },
+			decrypt: func(a *AES256, c []byte) ([]byte, error) { return a.DecryptCFB(c, consts.BLOCK_SIZE) },
+		},
+	} {
+		// Both ciphers read the same IV from the reader.
+		a, err := NewAES256FromKey(k, WithRand(&countingReader{}))
+		if err != nil {
+			panic(err)
+		}
+
+		b, err := NewAES256FromKey(k, WithRand(&countingReader{}))
+		if err != nil {
+			panic(err)
+		}
+
+		var buf bytes.Buffer
+
+		w, err := a.NewEncryptWriter(&buf, test.mode)
+		if err != nil {
+			panic(err)
+		}
+
+		for i := 0; i < len(plainText); i += 333 {
+			j := i + 333
+			if j > len(plainText) {
+				j = len(plainText)
+			}
+
+			if _, err := w.Write(plainText[i:j]); err != nil {
+				panic(err)
+			}
+		}
+
+		expected, err := test.encrypt(b)
+		if err != nil {
+			panic(err)
+		}
+
+		if !bytes.Equal(buf.Bytes(), expected) {
+			t.Fatalf("FAILED: writer output does not match one-shot encryption in mode %d", test.mode)
+		}
+
+		r, err := a.NewDecryptReader(bytes.NewReader(expected), test.mode)
+		if err != nil {
+			panic(err)
+		}
+
+		decrypted, err := io.ReadAll(r)
+		if err != nil || !bytes.Equal(decrypted, plainText) {
+			t.Fatalf("FAILED: reader could not decrypt in mode %d", test.mode)
+		}
+
+		decrypted, err = test.decrypt(b, buf.Bytes())
+		if err != nil || !bytes.Equal(decrypted, plainText) {
+			t.Fatalf("FAILED: one-shot decryption of writer output failed in mode %d", test.mode)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright (c) 2023 Paweł Rybak
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package aes256go
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+
+	"github.com/wedkarz02/aes256go/src/consts"
+)
+
+// Maximum size of a data unit in blocks.
+const xtsMaxBlocks = 1 << 20
+
+// XTS implements the XTS-AES tweakable block cipher mode used
+// for storage encryption. It uses two keys: the first one encrypts
+// the data, the second one encrypts the tweak.
+//
+// XTS does not need an IV and does not expand the data, so it fits
+// in fixed size sectors, but it provides no authentication.
+//
+// https://ieeexplore.ieee.org/document/4493450
+type XTS struct {
+	cipher *AES256
+	tweak  *AES256
+}
+
+// NewXTS initializes new XTS-AES cipher with a combined raw key.
+//
+// The key has to be 64 bytes long for XTS-AES-256, which uses two
+// AES-256 keys. 32 byte key selects XTS-AES-128. Both halves of the
+// key have to be different.
+func NewXTS(k []byte, opts ...Option) (*XTS, error) {
+	if len(k) != 2*consts.KEY_SIZE_128 && len(k) != 2*consts.KEY_SIZE_256 {
+		return nil, errors.New("invalid key size")
+	}
+
+	if subtle.ConstantTimeCompare(k[:len(k)/2], k[len(k)/2:]) == 1 {
+		return nil, errors.New("XTS keys have to be different")
+	}
+
+	c, err := NewAES256FromKey(k[:len(k)/2], opts...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tweak, err := NewAES256FromKey(k[len(k)/2:], opts...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &XTS{cipher: c, tweak: tweak}, nil
+}
+
+// ClearKey sets all bytes of both keys to 0x00
+// to make sure that they can't be retrieved from memory.
+func (x *XTS) ClearKey() {
+	x.cipher.ClearKey()
+	x.tweak.ClearKey()
+}
+
+// Data encryption of a single sector using XTS mode. The tweak is derived
+// from the sector number, so every sector has to be given a unique one.
+//
+// The sector has to be at least one block long. If its size is not a multiple
+// of the block size, ciphertext stealing is used for the last block.
+//
+// https://ieeexplore.ieee.org/document/4493450
+func (x *XTS) EncryptSector(plainText []byte, sectorNum uint64) ([]byte, error) {
+	if err := checkXTSSize(len(plainText)); err != nil {
+		return nil, err
+	}
+
+	cipherText := make([]byte, len(plainText))
+	tweaks := x.tweaks(sectorNum, len(plainText))
+
+	full := len(plainText) / consts.BLOCK_SIZE * consts.BLOCK_SIZE
+	xtsBlocks(x.cipher.encryptBlocks, cipherText[:full], plainText[:full], tweaks)
+
+	if rem := len(plainText) - full; rem != 0 {
+		// The last full cipherText block is split: its head becomes
+		// the partial block, and its tail pads the last plainText block.
+		last := cipherText[full-consts.BLOCK_SIZE : full]
+		padded := make([]byte, consts.BLOCK_SIZE)
+
+		copy(padded, plainText[full:])
+		copy(padded[rem:], last[rem:])
+		copy(cipherText[full:], last[:rem])
+
+		xtsBlocks(x.cipher.encryptBlocks, last, padded, tweaks[full:])
+	}
+
+	return cipherText, nil
+}
+
+// Data decryption of a single sector using XTS mode. The sector number
+// has to be the same as the one used for encryption.
+//
+// https://ieeexplore.ieee.org/document/4493450
+func (x *XTS) DecryptSector(cipherText []byte, sectorNum uint64) ([]byte, error) {
+	if err := checkXTSSize(len(cipherText)); err != nil {
+		return nil, err
+	}
+
+	plainText := make([]byte, len(cipherText))
+	tweaks := x.tweaks(sectorNum, len(cipherText))
+
+	full := len(cipherText) / consts.BLOCK_SIZE * consts.BLOCK_SIZE
+	rem := len(cipherText) - full
+
+	if rem == 0 {
+		xtsBlocks(x.cipher.decryptBlocks, plainText, cipherText, tweaks)
+		return plainText, nil
+	}
+
+	// With ciphertext stealing, the last full block was encrypted
+	// with the tweak of the partial block, so it is decrypted first.
+	xtsBlocks(x.cipher.decryptBlocks, plainText[:full-consts.BLOCK_SIZE], cipherText[:full-consts.BLOCK_SIZE], tweaks)
+
+	last := plainText[full-consts.BLOCK_SIZE : full]
+	padded := make([]byte, consts.BLOCK_SIZE)
+
+	xtsBlocks(x.cipher.decryptBlocks, padded, cipherText[full-consts.BLOCK_SIZE:full], tweaks[full:])
+
+	copy(plainText[full:], padded[:rem])
+	copy(padded, cipherText[full:])
+
+	xtsBlocks(x.cipher.decryptBlocks, last, padded, tweaks[full-consts.BLOCK_SIZE:])
+
+	return plainText, nil
+}
+
+// Tweaks returns the tweak values for every block of a sector of
+// the given size. The initial tweak is the encrypted sector number,
+// each next one is the previous one multiplied by alpha in GF(2^128).
+func (x *XTS) tweaks(sectorNum uint64, size int) []byte {
+	n := (size + consts.BLOCK_SIZE - 1) / consts.BLOCK_SIZE
+	tweaks := make([]byte, n*consts.BLOCK_SIZE)
+
+	binary.LittleEndian.PutUint64(tweaks, sectorNum)
+	x.tweak.encryptBlocks(tweaks[:consts.BLOCK_SIZE], tweaks[:consts.BLOCK_SIZE])
+
+	for i := consts.BLOCK_SIZE; i < len(tweaks); i += consts.BLOCK_SIZE {
+		mulAlpha(tweaks[i:i+consts.BLOCK_SIZE], tweaks[i-consts.BLOCK_SIZE:i])
+	}
+
+	return tweaks
+}
+
+// XtsBlocks processes whole blocks of src into dst with the given
+// block function, masking them with the tweaks before and after.
+func xtsBlocks(fn func(dst, src []byte), dst, src, tweaks []byte) {
+	for i := range src {
+		dst[i] = src[i] ^ tweaks[i]
+	}
+
+	fn(dst, dst)
+
+	for i := range dst {
+		dst[i] ^= tweaks[i]
+	}
+}
+
+// MulAlpha multiplies the block by alpha in GF(2^128). Unlike dbl,
+// the block is treated as a little-endian integer.
+func mulAlpha(dst, src []byte) {
+	carry := src[consts.BLOCK_SIZE-1] >> 7
+
+	for i := consts.BLOCK_SIZE - 1; i > 0; i-- {
+		dst[i] = src[i]<<1 | src[i-1]>>7
+	}
+
+	// Constant time conditional reduction.
+	dst[0] = src[0]<<1 ^ 0x87&-carry
+}
+
+// CheckXTSSize validates the size of a data unit.
+func checkXTSSize(size int) error {
+	if size < consts.BLOCK_SIZE {
+		return errors.New("invalid sector size: sector too short")
+	}
+
+	if size > xtsMaxBlocks*consts.BLOCK_SIZE {
+		return errors.New("invalid sector size: sector too large")
+	}
+
+	return nil
+}